@@ -0,0 +1,237 @@
+package conf
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/common/hexutil"
+	"github.com/bolaxy/crypto"
+)
+
+var (
+	InsufficientAttestations = errors.New("insufficient genesis attestations")
+	GenesisHashMismatch      = errors.New("attestations are for a different genesis")
+)
+
+// AttestationThreshold 不为 nil 时，TryLoadGenesis 要求 genesis 文件旁的签名文件(见 AttestationsFile)
+// 中至少有 AttestationThreshold(genesis) 个共识账户的有效签名，否则拒绝载入
+var AttestationThreshold func(g *Genesis) int
+
+// SuperMajorityThreshold 为常用的 AttestationThreshold：已载入 Global 的 PeerSet 时使用
+// PeerSet.SuperMajority()，否则使用共识账户数的 2/3 + 1
+func SuperMajorityThreshold(g *Genesis) int {
+	if ps := Global.GetPeers(); ps != nil {
+		return ps.SuperMajority()
+	}
+
+	return 2*len(g.ConsensusAccounts)/3 + 1
+}
+
+// GenesisAttestation 为一个共识账户对 Genesis.Hash() 的签名
+type GenesisAttestation struct {
+	Account   string `json:"account"`
+	Signature string `json:"signature"`
+}
+
+// SignGenesis 使用节点私钥 key 对 g 的哈希签名
+func SignGenesis(g *Genesis, key *ecdsa.PrivateKey) (*GenesisAttestation, error) {
+	hash, err := g.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GenesisAttestation{
+		Account:   crypto.PubkeyToAddress(key.PublicKey).Hex(),
+		Signature: hexutil.Encode(sig),
+	}, nil
+}
+
+// Verify 检查签名是否由 Account 对 hash 作出
+func (a *GenesisAttestation) Verify(hash []byte) error {
+	sig, err := decodeHex(a.Signature)
+	if err != nil {
+		return err
+	}
+
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+
+	if signer := crypto.PubkeyToAddress(*pub); signer != common.HexToAddress(a.Account) {
+		return fmt.Errorf("signed by %s, not %s", signer.Hex(), a.Account)
+	}
+
+	return nil
+}
+
+// AttestationBundle 汇总同一份 genesis 的所有签名
+type AttestationBundle struct {
+	GenesisHash  string                `json:"genesis-hash"`
+	Attestations []*GenesisAttestation `json:"attestations"`
+}
+
+// NewAttestationBundle 创建 g 的空签名集合
+func NewAttestationBundle(g *Genesis) (*AttestationBundle, error) {
+	hash, err := g.HexHash()
+	if err != nil {
+		return nil, err
+	}
+
+	return &AttestationBundle{GenesisHash: hash}, nil
+}
+
+// Add 校验并加入一个签名，同一账户的旧签名会被替换
+func (b *AttestationBundle) Add(a *GenesisAttestation) error {
+	hash, err := hexutil.Decode(b.GenesisHash)
+	if err != nil {
+		return err
+	}
+
+	if err := a.Verify(hash); err != nil {
+		return err
+	}
+
+	addr := common.HexToAddress(a.Account)
+	for i, old := range b.Attestations {
+		if common.HexToAddress(old.Account) == addr {
+			b.Attestations[i] = a
+			return nil
+		}
+	}
+
+	b.Attestations = append(b.Attestations, a)
+	return nil
+}
+
+// Verify 返回签署了 g 的共识账户，每个账户只计一次。不属于共识账户或签名无效的条目以
+// attestations[i] 标明下标汇总在 ValidationErrors 中，不影响其他条目的计数
+func (b *AttestationBundle) Verify(g *Genesis) ([]common.Address, error) {
+	hash, err := g.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(b.GenesisHash, hexutil.Encode(hash)) {
+		return nil, fmt.Errorf("%w: bundle has %s, genesis has %s", GenesisHashMismatch, b.GenesisHash, hexutil.Encode(hash))
+	}
+
+	consensus := make(map[common.Address]bool, len(g.ConsensusAccounts))
+	for _, account := range g.ConsensusAccounts {
+		consensus[common.HexToAddress(account)] = true
+	}
+
+	var (
+		errs   ValidationErrors
+		signed []common.Address
+		seen   = make(map[common.Address]bool, len(b.Attestations))
+	)
+
+	for i, a := range b.Attestations {
+		path := fmt.Sprintf("attestations[%d]", i)
+		addr := common.HexToAddress(a.Account)
+		if !consensus[addr] {
+			errs.add(path, "%s is not a consensus account", a.Account)
+			continue
+		}
+
+		if err := a.Verify(hash); err != nil {
+			errs.add(path, "%v", err)
+			continue
+		}
+
+		if !seen[addr] {
+			seen[addr] = true
+			signed = append(signed, addr)
+		}
+	}
+
+	return signed, errs.err()
+}
+
+// Require 在有效签名数少于 threshold 时返回包装 InsufficientAttestations 的错误
+func (b *AttestationBundle) Require(g *Genesis, threshold int) error {
+	signed, err := b.Verify(g)
+	if errors.Is(err, GenesisHashMismatch) {
+		return err
+	}
+
+	if len(signed) < threshold {
+		if err != nil {
+			return fmt.Errorf("%w: %d of %d required (%v)", InsufficientAttestations, len(signed), threshold, err)
+		}
+		return fmt.Errorf("%w: %d of %d required", InsufficientAttestations, len(signed), threshold)
+	}
+
+	return nil
+}
+
+// WriteTo 以 JSON 格式写入 w
+func (b *AttestationBundle) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// Save 将签名集合写入 path
+func (b *AttestationBundle) Save(path string) error {
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// ReadAttestationBundle 从 r 读取 JSON 格式的签名集合
+func ReadAttestationBundle(r io.Reader) (*AttestationBundle, error) {
+	var b AttestationBundle
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// LoadAttestationBundle 从 path 读取签名集合
+func LoadAttestationBundle(path string) (*AttestationBundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadAttestationBundle(f)
+}
+
+// AttestationsFile 返回 genesis 文件对应的签名文件路径，如 genesis.toml 对应 genesis.attestations.json
+func AttestationsFile(genesisFile string) string {
+	return strings.TrimSuffix(genesisFile, filepath.Ext(genesisFile)) + ".attestations.json"
+}
+
+func requireAttestations(genesisFile string, g *Genesis) error {
+	b, err := LoadAttestationBundle(AttestationsFile(genesisFile))
+	if err != nil {
+		return fmt.Errorf("%w: %v", InsufficientAttestations, err)
+	}
+
+	return b.Require(g, AttestationThreshold(g))
+}
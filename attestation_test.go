@@ -0,0 +1,145 @@
+package conf
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bolaxy/crypto"
+)
+
+func TestGenesisAttestations(t *testing.T) {
+	var (
+		keys    []*ecdsa.PrivateKey
+		builder = NewGenesisBuilder().WithChainID("1337")
+	)
+
+	for i := 0; i < 3; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+		builder.WithConsensusAccount(crypto.PubkeyToAddress(key.PublicKey))
+	}
+
+	genesis, _, err := builder.Build()
+	if err != nil {
+		t.Fatalf("failed to build genesis. cause: %v\n", err)
+	}
+
+	bundle, err := NewAttestationBundle(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range keys[:2] {
+		a, err := SignGenesis(genesis, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bundle.Add(a); err != nil {
+			t.Fatalf("failed to add attestation. cause: %v\n", err)
+		}
+	}
+
+	forged, _ := SignGenesis(genesis, keys[2])
+	forged.Account = bundle.Attestations[0].Account
+	if err := bundle.Add(forged); err == nil {
+		t.Error("attestation with a mismatched account should be rejected")
+	}
+
+	outsider, _ := crypto.GenerateKey()
+	a, _ := SignGenesis(genesis, outsider)
+	if err := bundle.Add(a); err != nil {
+		t.Fatalf("valid signature should be accepted by Add. cause: %v\n", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := bundle.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err = ReadAttestationBundle(&buf)
+	if err != nil {
+		t.Fatalf("failed to read bundle. cause: %v\n", err)
+	}
+
+	signed, err := bundle.Verify(genesis)
+	if len(signed) != 2 {
+		t.Errorf("got %d valid attestations, want 2", len(signed))
+	}
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].Path != "attestations[2]" {
+		t.Errorf("outsider attestation should be reported, got %v", err)
+	}
+
+	if err := bundle.Require(genesis, 2); err != nil {
+		t.Errorf("threshold 2 should be met. cause: %v\n", err)
+	}
+	if err := bundle.Require(genesis, 3); !errors.Is(err, InsufficientAttestations) {
+		t.Errorf("threshold 3 should fail with InsufficientAttestations, got %v", err)
+	}
+
+	genesis.ChainID = "1338"
+	if _, err := bundle.Verify(genesis); !errors.Is(err, GenesisHashMismatch) {
+		t.Errorf("modified genesis should fail with GenesisHashMismatch, got %v", err)
+	}
+}
+
+func TestTryLoadGenesisRequiresAttestations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-attestation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data, err := ioutil.ReadFile(filepath.Join(testFilePath, "genesis.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "genesis.toml"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	AttestationThreshold = func(*Genesis) int { return 1 }
+	defer func() { AttestationThreshold = nil }()
+
+	if _, err := TryLoadGenesis(dir); !errors.Is(err, InsufficientAttestations) {
+		t.Fatalf("missing attestations should fail, got %v", err)
+	}
+
+	key, err := LoadKey(testKeystore, testPwdFile, testSelfPeer(t, "node0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genesis, err := GetGenesisFromFile(filepath.Join(dir, "genesis.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, _ := NewAttestationBundle(genesis)
+	a, err := SignGenesis(genesis, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bundle.Add(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := bundle.Save(AttestationsFile(filepath.Join(dir, "genesis.toml"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := TryLoadGenesis(dir); err != nil {
+		t.Fatalf("attested genesis should load. cause: %v\n", err)
+	}
+
+	AttestationThreshold = SuperMajorityThreshold
+	if _, err := TryLoadGenesis(dir); !errors.Is(err, InsufficientAttestations) {
+		t.Errorf("one of three attestations should not meet the super majority, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package conf
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/common/hexutil"
+)
+
+// ContractSpec 描述 PoA 或 Launcher 合约
+type ContractSpec struct {
+	Address common.Address
+	Balance *big.Int
+	Abi     string
+	SubAbi  string
+	Code    []byte
+	Storage map[common.Hash]common.Hash
+}
+
+// GenesisBuilder 以类型化的参数构造 Genesis，所有方法都返回 builder 本身以便链式调用
+type GenesisBuilder struct {
+	genesis Genesis
+	allocs  map[common.Address]int
+	errs    ValidationErrors
+}
+
+// NewGenesisBuilder 创建空的 GenesisBuilder
+func NewGenesisBuilder() *GenesisBuilder {
+	return &GenesisBuilder{
+		allocs: make(map[common.Address]int),
+	}
+}
+
+func (b *GenesisBuilder) WithVersion(version uint) *GenesisBuilder {
+	b.genesis.Version = version
+	return b
+}
+
+func (b *GenesisBuilder) WithChainID(chainID string) *GenesisBuilder {
+	b.genesis.ChainID = chainID
+	return b
+}
+
+func (b *GenesisBuilder) WithCoinBase(addr common.Address) *GenesisBuilder {
+	b.genesis.CoinBase = addr.Hex()
+	return b
+}
+
+func (b *GenesisBuilder) WithExtraData(data []byte) *GenesisBuilder {
+	b.genesis.ExtraData = encodeBytes(data)
+	return b
+}
+
+// WithConsensusAccount 追加共识账户，重复添加同一地址无效
+func (b *GenesisBuilder) WithConsensusAccount(addr common.Address) *GenesisBuilder {
+	for _, a := range b.genesis.ConsensusAccounts {
+		if common.HexToAddress(a) == addr {
+			return b
+		}
+	}
+
+	b.genesis.ConsensusAccounts = append(b.genesis.ConsensusAccounts, addr.Hex())
+	return b
+}
+
+// Fund 为 addr 增加 amount 的初始余额，多次调用会累加
+func (b *GenesisBuilder) Fund(addr common.Address, amount *big.Int) *GenesisBuilder {
+	if amount == nil || amount.Sign() < 0 {
+		b.errs.add(fmt.Sprintf("alloc[%s].balance", addr.Hex()), "must be a non-negative amount")
+		return b
+	}
+
+	a := b.alloc(addr)
+	balance, err := ParseBalance(a.Balance)
+	if err != nil {
+		balance = new(big.Int)
+	}
+	a.Balance = new(big.Int).Add(balance, amount).String()
+	return b
+}
+
+// Authorise 将 addr 的初始分配标记为 authorising
+func (b *GenesisBuilder) Authorise(addr common.Address) *GenesisBuilder {
+	b.alloc(addr).Authorising = true
+	return b
+}
+
+// WithContract 在 addr 上部署合约代码和初始存储
+func (b *GenesisBuilder) WithContract(addr common.Address, code []byte, storage map[common.Hash]common.Hash) *GenesisBuilder {
+	a := b.alloc(addr)
+	a.Code = encodeBytes(code)
+	a.Storage = translateFromHashes(storage)
+	return b
+}
+
+func (b *GenesisBuilder) WithPoa(spec ContractSpec) *GenesisBuilder {
+	b.genesis.Poa = spec.poaMap()
+	return b
+}
+
+func (b *GenesisBuilder) WithLauncher(spec ContractSpec) *GenesisBuilder {
+	b.genesis.Launcher = spec.poaMap()
+	return b
+}
+
+// Build 校验并返回构造出的 Genesis 及其十六进制哈希，builder 之后仍可继续使用
+func (b *GenesisBuilder) Build() (*Genesis, string, error) {
+	if len(b.errs) > 0 {
+		return nil, "", b.errs
+	}
+
+	g := b.genesis.copy()
+	if err := g.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	hash, err := g.HexHash()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return g, hash, nil
+}
+
+func (b *GenesisBuilder) alloc(addr common.Address) *Alloc {
+	if i, ok := b.allocs[addr]; ok {
+		return &b.genesis.Alloc[i]
+	}
+
+	b.allocs[addr] = len(b.genesis.Alloc)
+	b.genesis.Alloc = append(b.genesis.Alloc, Alloc{Account: addr.Hex(), Balance: "0"})
+	return &b.genesis.Alloc[len(b.genesis.Alloc)-1]
+}
+
+func (spec ContractSpec) poaMap() *PoaMap {
+	balance := "0"
+	if spec.Balance != nil {
+		balance = spec.Balance.String()
+	}
+
+	return &PoaMap{
+		Address: spec.Address.Hex(),
+		Balance: balance,
+		Abi:     spec.Abi,
+		SubAbi:  spec.SubAbi,
+		Code:    encodeBytes(spec.Code),
+		Storage: translateFromHashes(spec.Storage),
+	}
+}
+
+// encodeBytes 将非空的字节编码为 0x 开头的十六进制，空字节保持为空字符串
+func encodeBytes(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	return hexutil.Encode(data)
+}
+
+func translateFromHashes(storage map[common.Hash]common.Hash) map[string]string {
+	if len(storage) == 0 {
+		return nil
+	}
+
+	store := make(map[string]string, len(storage))
+	for k, v := range storage {
+		store[k.Hex()] = v.Hex()
+	}
+
+	return store
+}
@@ -0,0 +1,66 @@
+package conf
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/bolaxy/common"
+)
+
+func TestGenesisBuilder(t *testing.T) {
+	var (
+		node0 = common.HexToAddress("0x51BaAb5243DB87CbEd2beBebAD825255E5061f4D")
+		node1 = common.HexToAddress("0x94DD016aD0DbcA14e42e4Ec2E57710F190D48e92")
+		token = common.HexToAddress("0x1234567890123456789012345678901234567890")
+		ether = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	)
+
+	b := NewGenesisBuilder().
+		WithChainID("1337").
+		WithCoinBase(node0).
+		WithConsensusAccount(node0).
+		WithConsensusAccount(node1).
+		WithConsensusAccount(node0).
+		Fund(node0, ether).
+		Fund(node0, ether).
+		Fund(node1, ether).
+		Authorise(node0).
+		WithContract(token, []byte{0x60, 0x80}, map[common.Hash]common.Hash{
+			common.BigToHash(big.NewInt(0)): common.BigToHash(big.NewInt(2)),
+		}).
+		WithPoa(ContractSpec{
+			Address: common.HexToAddress("0xabbaabbaabbaabbaabbaabbaabbaabbaabbaabba"),
+			Abi:     "[]",
+			Code:    []byte{0x60, 0x80},
+		})
+
+	genesis, hash, err := b.Build()
+	if err != nil {
+		t.Fatalf("failed to build genesis. cause: %v\n", err)
+	}
+
+	if len(genesis.ConsensusAccounts) != 2 || len(genesis.Alloc) != 3 {
+		t.Fatalf("unexpected genesis: %+v", genesis)
+	}
+
+	if genesis.Alloc[0].Balance != "2000000000000000000" || !genesis.Alloc[0].Authorising {
+		t.Fatalf("unexpected alloc for node0: %+v", genesis.Alloc[0])
+	}
+
+	if h, _ := genesis.HexHash(); h != hash {
+		t.Fatalf("Build returned hash %s, genesis hashes to %s", hash, h)
+	}
+
+	b.Fund(node1, ether)
+	if genesis.Alloc[2].Balance != "0" || genesis.Alloc[1].Balance != "1000000000000000000" {
+		t.Fatal("builder changes leaked into built genesis")
+	}
+
+	if _, _, err := NewGenesisBuilder().Fund(node0, ether).Build(); err == nil {
+		t.Fatal("expected missing chain id to fail validation")
+	}
+
+	if _, _, err := NewGenesisBuilder().WithChainID("1").Fund(node0, big.NewInt(-1)).Build(); err == nil {
+		t.Fatal("expected negative amount to be rejected")
+	}
+}
@@ -31,6 +31,10 @@ var (
 	defaultKeystore   = "keystore"
 	defaultPwdFile    = "password"
 	defaultDbFile     = "db"
+	defaultLogPath    = "/opt/runbolaxy/log"
+	defaultLogName    = "bolaxy.log"
+	defaultRotTime    = uint(24)
+	defaultRotCount   = uint(7)
 
 	Key        *ecdsa.PrivateKey
 	GensisData *Genesis
@@ -60,12 +64,22 @@ func DefaultNetConfig() *NetConfig {
 	}
 }
 
+func DefaultLogConfig() *LogConfig {
+	return &LogConfig{
+		LogPath:       defaultLogPath,
+		LogName:       defaultLogName,
+		RotationTime:  defaultRotTime,
+		RotationCount: defaultRotCount,
+	}
+}
+
 func DefaultConfig() *Config {
 	return &Config{
 		Self:      "",
 		Verbose:   true,
 		DataCnf:   DefaultDataConfig(),
 		NetCnf:    DefaultNetConfig(),
+		LogCnf:    DefaultLogConfig(),
 		CacheSize: defaultCacheSize,
 		SyncLimit: defaultSyncLimit,
 	}
@@ -103,6 +117,11 @@ type Config struct {
 	Peerlist  []*Peer     `mapstructure:"peerSet"`
 	CacheSize int         `mapstructure:"cache-size"`
 	SyncLimit int         `mapstructure:"sync-limit"`
+
+	mu     sync.RWMutex
+	key    *ecdsa.PrivateKey
+	peers  *PeerSet
+	source string
 }
 
 type PeerList []*Peer
@@ -141,8 +160,13 @@ func SelfPeer(alias string, list []*Peer) *Peer {
 	return nil
 }
 
+// OtherPeers 返回 list 中除 excludeAlias 之外的 peer，list 为 nil 或为空时返回 nil
 func OtherPeers(excludeAlias string, list *PeerSet) PeerList {
-	ps := make([]*Peer, 0, len(list.Peers)-1)
+	if list == nil || len(list.Peers) == 0 {
+		return nil
+	}
+
+	ps := make([]*Peer, 0, len(list.Peers))
 	for _, p := range list.Peers {
 		if p.Alias != excludeAlias {
 			ps = append(ps, p)
@@ -153,29 +177,50 @@ func OtherPeers(excludeAlias string, list *PeerSet) PeerList {
 }
 
 func (cnf *Config) SelfPeer() *Peer {
+	cnf.mu.RLock()
+	defer cnf.mu.RUnlock()
 	return SelfPeer(cnf.Self, cnf.Peerlist)
 }
 
 func (cnf *Config) OtherPeers() PeerList {
-	return OtherPeers(cnf.Self, Peers)
+	return OtherPeers(cnf.Self, cnf.GetPeers())
 }
 
 func (cnf *Config) GetLogger() *logrus.Entry {
 	logMux.Lock()
 	defer logMux.Unlock()
 	if logger == nil {
-		logLevel := "info"
-		if cnf.Verbose {
-			logLevel = "debug"
-		}
-
-		logger = newLogger(logLevel, cnf.LogCnf.RotationCount,
+		logger = newLogger(verboseLevel(cnf.Verbose), cnf.LogCnf.RotationCount,
 			filepath.Join(cnf.LogCnf.LogPath, cnf.LogCnf.LogName), time.Duration(cnf.LogCnf.RotationTime)*time.Hour)
 	}
 
 	return logger
 }
 
+// setLogLevel 调整已创建的 logger 的日志级别，用于配置热更新
+func setLogLevel(verbose bool) {
+	logMux.Lock()
+	defer logMux.Unlock()
+	if logger != nil {
+		logger.Logger.SetLevel(LogLevel(verboseLevel(verbose)))
+	}
+}
+
+func verboseLevel(verbose bool) string {
+	if verbose {
+		return "debug"
+	}
+
+	return "info"
+}
+
+// Source 返回载入本配置时实际使用的配置文件路径，未从文件载入时为空
+func (cnf *Config) Source() string {
+	cnf.mu.RLock()
+	defer cnf.mu.RUnlock()
+	return cnf.source
+}
+
 func (cnf *Config) GetDBFile() string {
 	return filepath.Join(cnf.DataCnf.DataDir, cnf.DataCnf.DbFile)
 }
@@ -184,12 +229,64 @@ func (cnf *Config) GetGenesis() string {
 	return filepath.Join(cnf.DataCnf.DataDir, cnf.DataCnf.Genesis)
 }
 
+func (cnf *Config) GetKeystore() string {
+	return filepath.Join(cnf.DataCnf.DataDir, cnf.DataCnf.Keystore)
+}
+
+func (cnf *Config) GetPwdFile() string {
+	return filepath.Join(cnf.DataCnf.DataDir, cnf.DataCnf.PwdFile)
+}
+
+// replace 用 src 的内容替换 cnf，用于将重新载入的配置应用到 Global 上
+func (cnf *Config) replace(src *Config) {
+	src.mu.RLock()
+	defer src.mu.RUnlock()
+	cnf.mu.Lock()
+	defer cnf.mu.Unlock()
+
+	cnf.Self = src.Self
+	cnf.Verbose = src.Verbose
+	cnf.DataCnf = src.DataCnf
+	cnf.NetCnf = src.NetCnf
+	cnf.LogCnf = src.LogCnf
+	cnf.Peerlist = src.Peerlist
+	cnf.CacheSize = src.CacheSize
+	cnf.SyncLimit = src.SyncLimit
+	cnf.key = src.key
+	cnf.peers = src.peers
+	cnf.source = src.source
+	if cnf == Global {
+		Peers = src.peers
+	}
+}
+
 func (cnf *Config) GetKey() *ecdsa.PrivateKey {
-	return Key
+	cnf.mu.RLock()
+	defer cnf.mu.RUnlock()
+	return cnf.key
 }
 
 func (cnf *Config) GetPeers() *PeerSet {
-	return Peers
+	cnf.mu.RLock()
+	defer cnf.mu.RUnlock()
+	return cnf.peers
+}
+
+// SetPeers 校验并替换当前的 PeerSet，同时更新 Peerlist，保证 GetPeers 与 SelfPeer 一致。
+// 对 Global 调用时，包级变量 Peers 也会同步更新
+func (cnf *Config) SetPeers(peerSet *PeerSet) error {
+	if err := ValidatePeerList(cnf.Self, peerSet.Peers); err != nil {
+		return err
+	}
+
+	cnf.mu.Lock()
+	defer cnf.mu.Unlock()
+	cnf.Peerlist = peerSet.Peers
+	cnf.peers = peerSet
+	if cnf == Global {
+		Peers = peerSet
+	}
+	return nil
 }
 
 func (cnf *Config) GensisData() *Genesis {
@@ -0,0 +1,101 @@
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writePartialConfig 在临时目录中写入只包含 section 的配置文件，self、peerSet 和
+// datadir 取自 testdata，保证载入时能通过校验并找到 keystore
+func writePartialConfig(t *testing.T, section string) string {
+	full, err := ioutil.ReadFile(filepath.Join(testFilePath, "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	peers := string(full[strings.Index(string(full), "[[peerSet]]"):])
+
+	datadir, err := filepath.Abs(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "bolaxy-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := "self = \"node0\"\n\n[datacnf]\ndatadir = \"" + datadir + "\"\n" + section + "\n" + peers
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.toml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestPartialConfigMerge(t *testing.T) {
+	datadir, _ := filepath.Abs(testFilePath)
+
+	cases := []struct {
+		name    string
+		section string
+		expect  func(*Config)
+	}{
+		{
+			name:    "netcnf heartbeat only",
+			section: "[netcnf]\nheartbeat = \"250ms\"\n",
+			expect:  func(c *Config) { c.NetCnf.Heartbeat = 250 * time.Millisecond },
+		},
+		{
+			name:    "datacnf db only",
+			section: "db = \"chaindata\"\n",
+			expect:  func(c *Config) { c.DataCnf.DbFile = "chaindata" },
+		},
+		{
+			name:    "logcnf logname only",
+			section: "[logcnf]\nlogname = \"node.log\"\n",
+			expect:  func(c *Config) { c.LogCnf.LogName = "node.log" },
+		},
+		{
+			name:    "top level only",
+			section: "",
+			expect:  func(c *Config) {},
+		},
+	}
+
+	for _, c := range cases {
+		dir := writePartialConfig(t, c.section)
+
+		// load twice through Global so that values from a previous load cannot leak
+		if _, err := TryLoadConfig(dir); err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("%s: failed to load config. cause: %v\n", c.name, err)
+		}
+		cnf, err := TryLoadConfig(dir)
+		os.RemoveAll(dir)
+		if err != nil {
+			t.Fatalf("%s: failed to load config. cause: %v\n", c.name, err)
+		}
+
+		want := DefaultConfig()
+		want.DataCnf.DataDir = datadir
+		c.expect(want)
+
+		if !reflect.DeepEqual(cnf.NetCnf, want.NetCnf) {
+			t.Errorf("%s: netcnf = %+v, want %+v", c.name, cnf.NetCnf, want.NetCnf)
+		}
+		if !reflect.DeepEqual(cnf.DataCnf, want.DataCnf) {
+			t.Errorf("%s: datacnf = %+v, want %+v", c.name, cnf.DataCnf, want.DataCnf)
+		}
+		if !reflect.DeepEqual(cnf.LogCnf, want.LogCnf) {
+			t.Errorf("%s: logcnf = %+v, want %+v", c.name, cnf.LogCnf, want.LogCnf)
+		}
+		if cnf.CacheSize != want.CacheSize || cnf.SyncLimit != want.SyncLimit || cnf.Verbose != want.Verbose {
+			t.Errorf("%s: top level fields not defaulted: %+v", c.name, cnf)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bolaxy/common"
+)
+
+// ConsensusMismatch 表示 Genesis.ConsensusAccounts 与配置中的 peerSet 不一致
+var ConsensusMismatch = errors.New("consensus accounts do not match peer set")
+
+// ConsensusMismatchError 列出没有共识账户的 peer 和没有对应 peer 的共识账户
+type ConsensusMismatchError struct {
+	PeersWithoutAccount []*Peer
+	AccountsWithoutPeer []string
+}
+
+func (e *ConsensusMismatchError) Error() string {
+	var parts []string
+
+	if len(e.PeersWithoutAccount) > 0 {
+		peers := make([]string, 0, len(e.PeersWithoutAccount))
+		for _, p := range e.PeersWithoutAccount {
+			if addr, err := p.AccountAddress(); err == nil {
+				peers = append(peers, fmt.Sprintf("%s (%s)", p.Alias, addr.Hex()))
+			} else {
+				peers = append(peers, fmt.Sprintf("%s (%v)", p.Alias, err))
+			}
+		}
+		parts = append(parts, "peers without account: "+strings.Join(peers, ", "))
+	}
+
+	if len(e.AccountsWithoutPeer) > 0 {
+		parts = append(parts, "accounts without peer: "+strings.Join(e.AccountsWithoutPeer, ", "))
+	}
+
+	return fmt.Sprintf("%v: %s", ConsensusMismatch, strings.Join(parts, "; "))
+}
+
+func (e *ConsensusMismatchError) Unwrap() error {
+	return ConsensusMismatch
+}
+
+// CheckConsensusAccounts 由每个 validator 的公钥推导账户地址，与 g.ConsensusAccounts 比较，
+// observer 和 bootstrap peer 不需要共识账户。公钥无法解析的 peer 视为没有共识账户
+func CheckConsensusAccounts(g *Genesis, peers PeerList) error {
+	var (
+		mismatch ConsensusMismatchError
+		byPeer   = make(map[common.Address]bool, len(peers))
+	)
+
+	validators := make(PeerList, 0, len(peers))
+	for _, p := range peers {
+		if p.IsValidator() {
+			validators = append(validators, p)
+		}
+	}
+	peers = validators
+
+	for _, p := range peers {
+		addr, err := p.AccountAddress()
+		if err != nil {
+			mismatch.PeersWithoutAccount = append(mismatch.PeersWithoutAccount, p)
+			continue
+		}
+		byPeer[addr] = true
+	}
+
+	accounts := make(map[common.Address]bool, len(g.ConsensusAccounts))
+	for _, account := range g.ConsensusAccounts {
+		addr := common.HexToAddress(account)
+		accounts[addr] = true
+		if !byPeer[addr] {
+			mismatch.AccountsWithoutPeer = append(mismatch.AccountsWithoutPeer, account)
+		}
+	}
+
+	for _, p := range peers {
+		if addr, err := p.AccountAddress(); err == nil && !accounts[addr] {
+			mismatch.PeersWithoutAccount = append(mismatch.PeersWithoutAccount, p)
+		}
+	}
+
+	if len(mismatch.PeersWithoutAccount) == 0 && len(mismatch.AccountsWithoutPeer) == 0 {
+		return nil
+	}
+
+	return &mismatch
+}
+
+// CheckGenesis 检查 g 的共识账户与本配置的 peerSet 一致。TryLoadConfig 与 TryLoadGenesis 会对 Global
+// 自动检查，使用 LoadConfig 载入独立配置的调用方可以用它检查自己的配置
+func (cnf *Config) CheckGenesis(g *Genesis) error {
+	if ps := cnf.GetPeers(); ps != nil {
+		return CheckConsensusAccounts(g, ps.Peers)
+	}
+
+	return CheckConsensusAccounts(g, cnf.Peerlist)
+}
@@ -0,0 +1,118 @@
+package conf
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckConsensusAccounts(t *testing.T) {
+	genesis, err := TryLoadGenesis(testFilePath)
+	if err != nil {
+		t.Fatalf("failed to load genesis file. cause: %v\n", err)
+	}
+
+	// testPeers 缺少 node0，且账户排序不同
+	err = CheckConsensusAccounts(genesis, testPeers())
+	var mismatch *ConsensusMismatchError
+	if !errors.As(err, &mismatch) || !errors.Is(err, ConsensusMismatch) {
+		t.Fatalf("expected ConsensusMismatchError, got %v", err)
+	}
+
+	if len(mismatch.PeersWithoutAccount) != 0 {
+		t.Errorf("unexpected peers without account: %v", mismatch.PeersWithoutAccount)
+	}
+	if len(mismatch.AccountsWithoutPeer) != 1 || mismatch.AccountsWithoutPeer[0] != genesis.ConsensusAccounts[0] {
+		t.Errorf("expected %s without peer, got %v", genesis.ConsensusAccounts[0], mismatch.AccountsWithoutPeer)
+	}
+
+	// 修改返回值不影响 GensisData
+	genesis.ConsensusAccounts = genesis.ConsensusAccounts[:1]
+	if len(GensisData.ConsensusAccounts) == 1 {
+		t.Fatal("editing the loaded genesis modified GensisData")
+	}
+
+	err = CheckConsensusAccounts(genesis, testPeers())
+	if !errors.As(err, &mismatch) || len(mismatch.PeersWithoutAccount) != 2 {
+		t.Fatalf("expected two peers without account, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "node1 (0x94DD016aD0DbcA14e42e4Ec2E57710F190D48e92)") {
+		t.Errorf("error should name the peer and its derived address: %v", err)
+	}
+}
+
+func TestTryLoadGenesisChecksPeerSet(t *testing.T) {
+	if _, err := TryLoadGenesis(testFilePath); err != nil {
+		t.Fatalf("failed to load genesis file. cause: %v\n", err)
+	}
+
+	if _, err := TryLoadConfig(testFilePath); err != nil {
+		t.Fatalf("failed to load config file. cause: %v\n", err)
+	}
+
+	if _, err := TryLoadGenesis(testFilePath); err != nil {
+		t.Fatalf("matching genesis should load. cause: %v\n", err)
+	}
+
+	dir := writeMismatchedGenesis(t)
+	defer os.RemoveAll(dir)
+
+	if _, err := TryLoadGenesis(dir); !errors.Is(err, ConsensusMismatch) {
+		t.Fatalf("expected ConsensusMismatch, got %v", err)
+	}
+}
+
+func TestTryLoadConfigChecksGenesis(t *testing.T) {
+	dir := writeMismatchedGenesis(t)
+	defer os.RemoveAll(dir)
+
+	// 恢复为载入了匹配的创世文件和配置的状态
+	defer func() {
+		Global.replace(DefaultConfig())
+		TryLoadGenesis(testFilePath)
+		TryLoadConfig(testFilePath)
+	}()
+
+	Global.replace(DefaultConfig())
+	genesis, err := TryLoadGenesis(dir)
+	if err != nil {
+		t.Fatalf("genesis should load before config. cause: %v\n", err)
+	}
+	if GensisData == nil || GensisData == genesis || len(GensisData.ConsensusAccounts) != len(genesis.ConsensusAccounts) {
+		t.Fatal("TryLoadGenesis did not store a copy in GensisData")
+	}
+
+	if _, err := TryLoadConfig(testFilePath); !errors.Is(err, ConsensusMismatch) {
+		t.Fatalf("expected ConsensusMismatch, got %v", err)
+	}
+
+	cnf, err := LoadConfig(testFilePath)
+	if err != nil {
+		t.Fatalf("LoadConfig should not check genesis. cause: %v\n", err)
+	}
+	if err := cnf.CheckGenesis(genesis); !errors.Is(err, ConsensusMismatch) {
+		t.Fatalf("expected ConsensusMismatch, got %v", err)
+	}
+}
+
+// writeMismatchedGenesis 写入缺少 node2 共识账户的创世文件，返回所在目录
+func writeMismatchedGenesis(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "bolaxy-consensus")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(testFilePath, "genesis.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = []byte(strings.Replace(string(data), `    "0x07aD8Bf94B51EFB1bE609f3340976633e5E2dF5E",`+"\n", "", 1))
+	if err := ioutil.WriteFile(filepath.Join(dir, "genesis.toml"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
@@ -0,0 +1,289 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bolaxy/common"
+)
+
+// GenesisDiffKind 标识两份 Genesis 之间差异的类型
+type GenesisDiffKind string
+
+const (
+	DiffVersion            GenesisDiffKind = "version changed"
+	DiffChainID            GenesisDiffKind = "chain id changed"
+	DiffCoinBase           GenesisDiffKind = "coinbase changed"
+	DiffExtraData          GenesisDiffKind = "extra data changed"
+	DiffConsensusAdded     GenesisDiffKind = "consensus account added"
+	DiffConsensusRemoved   GenesisDiffKind = "consensus account removed"
+	DiffConsensusReordered GenesisDiffKind = "consensus accounts reordered"
+	DiffAllocAdded         GenesisDiffKind = "alloc added"
+	DiffAllocRemoved       GenesisDiffKind = "alloc removed"
+	DiffAllocReordered     GenesisDiffKind = "allocs reordered"
+	DiffAllocAccount       GenesisDiffKind = "alloc account spelling changed"
+	DiffBalance            GenesisDiffKind = "balance changed"
+	DiffCode               GenesisDiffKind = "code changed"
+	DiffAuthorising        GenesisDiffKind = "authorising changed"
+	DiffStorageAdded       GenesisDiffKind = "storage slot added"
+	DiffStorageRemoved     GenesisDiffKind = "storage slot removed"
+	DiffStorageChanged     GenesisDiffKind = "storage slot changed"
+	DiffContractAdded      GenesisDiffKind = "contract added"
+	DiffContractRemoved    GenesisDiffKind = "contract removed"
+	DiffContractAddress    GenesisDiffKind = "contract address changed"
+	DiffContractABI        GenesisDiffKind = "contract ABI changed"
+	DiffContractSubABI     GenesisDiffKind = "contract sub ABI changed"
+)
+
+// GenesisDiff 描述一处差异，Path 指向发生差异的字段，如 alloc[0x…].storage[0x…]
+type GenesisDiff struct {
+	Kind GenesisDiffKind
+	Path string
+	Old  string
+	New  string
+}
+
+func (d GenesisDiff) String() string {
+	switch {
+	case len(d.Old) == 0 && len(d.New) > 0:
+		return fmt.Sprintf("+ %s: %s (%s)", d.Path, d.New, d.Kind)
+	case len(d.New) == 0 && len(d.Old) > 0:
+		return fmt.Sprintf("- %s: %s (%s)", d.Path, d.Old, d.Kind)
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s (%s)", d.Path, d.Old, d.New, d.Kind)
+	}
+}
+
+// DiffGenesis 列出 a 与 b 之间会影响 Genesis.Hash() 的差异。存储按 EncodeRLP 的方式归一化，
+// 只有顺序不同的存储不会被报告；alloc 按地址匹配，地址大小写不同时报告 DiffAllocAccount
+func DiffGenesis(a, b *Genesis) []GenesisDiff {
+	var diffs []GenesisDiff
+	add := func(kind GenesisDiffKind, path, old, new string) {
+		diffs = append(diffs, GenesisDiff{Kind: kind, Path: path, Old: old, New: new})
+	}
+
+	if va, vb := a.EncodingVersion(), b.EncodingVersion(); va != vb {
+		add(DiffVersion, "version", fmt.Sprint(va), fmt.Sprint(vb))
+	}
+
+	if a.ChainID != b.ChainID {
+		add(DiffChainID, "chain-id", a.ChainID, b.ChainID)
+	}
+
+	// GenesisV1 不编码 CoinBase 和 ExtraData，两边都使用 v1 时它们不影响哈希
+	if a.EncodingVersion() != GenesisV1 || b.EncodingVersion() != GenesisV1 {
+		if a.CoinBase != b.CoinBase {
+			add(DiffCoinBase, "coinbase", a.CoinBase, b.CoinBase)
+		}
+
+		if a.ExtraData != b.ExtraData {
+			add(DiffExtraData, "extra-data", a.ExtraData, b.ExtraData)
+		}
+	}
+
+	diffs = append(diffs, diffConsensusAccounts(a.ConsensusAccounts, b.ConsensusAccounts)...)
+	diffs = append(diffs, diffAllocs(a.Alloc, b.Alloc)...)
+	diffs = append(diffs, diffContract("poa", a.Poa, b.Poa)...)
+	diffs = append(diffs, diffContract("launcher", a.Launcher, b.Launcher)...)
+
+	return diffs
+}
+
+// RenderGenesisDiff 将差异渲染为便于阅读的文本，每行一处差异
+func RenderGenesisDiff(diffs []GenesisDiff) string {
+	if len(diffs) == 0 {
+		return "genesis files are identical\n"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d difference(s):\n", len(diffs))
+	for _, d := range diffs {
+		b.WriteString("  " + d.String() + "\n")
+	}
+
+	return b.String()
+}
+
+func diffConsensusAccounts(a, b []string) []GenesisDiff {
+	var (
+		diffs []GenesisDiff
+		inA   = make(map[string]bool, len(a))
+		inB   = make(map[string]bool, len(b))
+	)
+
+	for _, acc := range a {
+		inA[acc] = true
+	}
+	for _, acc := range b {
+		inB[acc] = true
+	}
+
+	for _, acc := range a {
+		if !inB[acc] {
+			diffs = append(diffs, GenesisDiff{Kind: DiffConsensusRemoved, Path: "consensus-accounts", Old: acc})
+		}
+	}
+	for _, acc := range b {
+		if !inA[acc] {
+			diffs = append(diffs, GenesisDiff{Kind: DiffConsensusAdded, Path: "consensus-accounts", New: acc})
+		}
+	}
+
+	if len(diffs) == 0 && strings.Join(a, ",") != strings.Join(b, ",") {
+		diffs = append(diffs, GenesisDiff{
+			Kind: DiffConsensusReordered,
+			Path: "consensus-accounts",
+			Old:  strings.Join(a, ", "),
+			New:  strings.Join(b, ", "),
+		})
+	}
+
+	return diffs
+}
+
+func diffAllocs(a, b []Alloc) []GenesisDiff {
+	var (
+		diffs  []GenesisDiff
+		inA    = make(map[common.Address]bool, len(a))
+		byAddr = make(map[common.Address]int, len(b))
+		oldOrd []string
+		newOrd []string
+	)
+
+	for _, x := range a {
+		inA[allocAddress(x.Account)] = true
+	}
+	for j, y := range b {
+		byAddr[allocAddress(y.Account)] = j
+	}
+
+	for _, x := range a {
+		addr := allocAddress(x.Account)
+		j, ok := byAddr[addr]
+		if !ok {
+			diffs = append(diffs, GenesisDiff{Kind: DiffAllocRemoved, Path: "alloc", Old: x.Account})
+			continue
+		}
+
+		oldOrd = append(oldOrd, addr.Hex())
+		diffs = append(diffs, diffAlloc(x, b[j])...)
+	}
+
+	for _, y := range b {
+		addr := allocAddress(y.Account)
+		if !inA[addr] {
+			diffs = append(diffs, GenesisDiff{Kind: DiffAllocAdded, Path: "alloc", New: y.Account})
+			continue
+		}
+
+		newOrd = append(newOrd, addr.Hex())
+	}
+
+	// 只比较两边都存在的 alloc 的相对顺序
+	if strings.Join(oldOrd, ",") != strings.Join(newOrd, ",") {
+		diffs = append(diffs, GenesisDiff{
+			Kind: DiffAllocReordered,
+			Path: "alloc",
+			Old:  strings.Join(oldOrd, ", "),
+			New:  strings.Join(newOrd, ", "),
+		})
+	}
+
+	return diffs
+}
+
+func diffAlloc(a, b Alloc) []GenesisDiff {
+	var (
+		diffs []GenesisDiff
+		path  = "alloc[" + a.Account + "]"
+	)
+
+	if a.Account != b.Account {
+		diffs = append(diffs, GenesisDiff{Kind: DiffAllocAccount, Path: path + ".account", Old: a.Account, New: b.Account})
+	}
+
+	if a.Balance != b.Balance {
+		diffs = append(diffs, GenesisDiff{Kind: DiffBalance, Path: path + ".balance", Old: a.Balance, New: b.Balance})
+	}
+
+	if a.Code != b.Code {
+		diffs = append(diffs, GenesisDiff{Kind: DiffCode, Path: path + ".code", Old: a.Code, New: b.Code})
+	}
+
+	if a.Authorising != b.Authorising {
+		diffs = append(diffs, GenesisDiff{
+			Kind: DiffAuthorising,
+			Path: path + ".authorising",
+			Old:  fmt.Sprint(a.Authorising),
+			New:  fmt.Sprint(b.Authorising),
+		})
+	}
+
+	return append(diffs, diffStorage(path+".storage", a.Storage, b.Storage)...)
+}
+
+func diffContract(path string, a, b *PoaMap) []GenesisDiff {
+	switch {
+	case a == nil && b == nil:
+		return nil
+	case a == nil:
+		return []GenesisDiff{{Kind: DiffContractAdded, Path: path, New: b.Address}}
+	case b == nil:
+		return []GenesisDiff{{Kind: DiffContractRemoved, Path: path, Old: a.Address}}
+	}
+
+	var diffs []GenesisDiff
+	fields := []struct {
+		kind     GenesisDiffKind
+		name     string
+		old, new string
+	}{
+		{DiffContractAddress, "address", a.Address, b.Address},
+		{DiffBalance, "balance", a.Balance, b.Balance},
+		{DiffContractABI, "abi", a.Abi, b.Abi},
+		{DiffContractSubABI, "subabi", a.SubAbi, b.SubAbi},
+		{DiffCode, "code", a.Code, b.Code},
+	}
+
+	for _, f := range fields {
+		if f.old != f.new {
+			diffs = append(diffs, GenesisDiff{Kind: f.kind, Path: path + "." + f.name, Old: f.old, New: f.new})
+		}
+	}
+
+	return append(diffs, diffStorage(path+".storage", a.Storage, b.Storage)...)
+}
+
+// diffStorage 比较按 translateFromStorage 归一化后的存储
+func diffStorage(path string, a, b map[string]string) []GenesisDiff {
+	var (
+		diffs []GenesisDiff
+		sa    = translateFromStorage(a)
+		sb    = translateFromStorage(b)
+		i, j  int
+	)
+
+	less := func(x, y string) bool { return alphabetic{{x}, {y}}.Less(0, 1) }
+	for i < len(sa) || j < len(sb) {
+		switch {
+		case j == len(sb) || (i < len(sa) && less(sa[i][0], sb[j][0])):
+			diffs = append(diffs, GenesisDiff{Kind: DiffStorageRemoved, Path: path + "[" + sa[i][0] + "]", Old: sa[i][1]})
+			i++
+		case i == len(sa) || less(sb[j][0], sa[i][0]):
+			diffs = append(diffs, GenesisDiff{Kind: DiffStorageAdded, Path: path + "[" + sb[j][0] + "]", New: sb[j][1]})
+			j++
+		default:
+			if sa[i][1] != sb[j][1] {
+				diffs = append(diffs, GenesisDiff{Kind: DiffStorageChanged, Path: path + "[" + sa[i][0] + "]", Old: sa[i][1], New: sb[j][1]})
+			}
+			i++
+			j++
+		}
+	}
+
+	return diffs
+}
+
+func allocAddress(account string) common.Address {
+	return common.HexToAddress(account)
+}
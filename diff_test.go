@@ -0,0 +1,124 @@
+package conf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDiffGenesis(t *testing.T) {
+	a, err := TryLoadGenesis(testFilePath, "genesis_all")
+	if err != nil {
+		t.Fatalf("failed to load genesis file. cause: %v\n", err)
+	}
+
+	b := a.copy()
+	if diffs := DiffGenesis(a, b); len(diffs) != 0 {
+		t.Fatalf("copy should not differ, got %v", diffs)
+	}
+
+	// 重建存储 map 不改变内容，不应报告差异
+	storage := make(map[string]string, len(b.Alloc[3].Storage))
+	for k, v := range b.Alloc[3].Storage {
+		storage[k] = v
+	}
+	b.Alloc[3].Storage = storage
+	if diffs := DiffGenesis(a, b); len(diffs) != 0 {
+		t.Fatalf("rebuilt storage should not differ, got %v", diffs)
+	}
+
+	slot := "0x0000000000000000000000000000000000000000000000000000000000000001"
+	b.ChainID = "1338"
+	b.ConsensusAccounts = b.ConsensusAccounts[:2]
+	b.Alloc[0].Balance = "1"
+	b.Alloc[3].Storage[slot] = "0x000000000000000000000000000000000000000000000000000000000000000b"
+	b.Poa.Abi = "[]"
+
+	want := []GenesisDiff{
+		{Kind: DiffChainID, Path: "chain-id", Old: "1337", New: "1338"},
+		{Kind: DiffConsensusRemoved, Path: "consensus-accounts", Old: a.ConsensusAccounts[2]},
+		{Kind: DiffBalance, Path: "alloc[" + a.Alloc[0].Account + "].balance", Old: a.Alloc[0].Balance, New: "1"},
+		{
+			Kind: DiffStorageChanged,
+			Path: "alloc[" + a.Alloc[3].Account + "].storage[" + slot + "]",
+			Old:  a.Alloc[3].Storage[slot],
+			New:  b.Alloc[3].Storage[slot],
+		},
+		{Kind: DiffContractABI, Path: "poa.abi", Old: a.Poa.Abi, New: "[]"},
+	}
+
+	diffs := DiffGenesis(a, b)
+	if len(diffs) != len(want) {
+		t.Fatalf("got %d diffs, want %d:\n%s", len(diffs), len(want), RenderGenesisDiff(diffs))
+	}
+	for i := range want {
+		if diffs[i] != want[i] {
+			t.Errorf("diff %d is %+v, want %+v", i, diffs[i], want[i])
+		}
+	}
+
+	text := RenderGenesisDiff(diffs)
+	if !strings.HasPrefix(text, "5 difference(s):") || !strings.Contains(text, "~ chain-id: 1337 -> 1338") {
+		t.Errorf("unexpected rendering:\n%s", text)
+	}
+}
+
+func TestDiffGenesisV1(t *testing.T) {
+	a, err := TryLoadGenesis(testFilePath, "genesis_all")
+	if err != nil {
+		t.Fatalf("failed to load genesis file. cause: %v\n", err)
+	}
+
+	a.Version = GenesisV1
+	b := a.copy()
+	b.CoinBase = "0x0000000000000000000000000000000000000001"
+	b.ExtraData = "0x01"
+
+	ha, _ := a.Hash()
+	hb, _ := b.Hash()
+	if !bytes.Equal(ha, hb) {
+		t.Fatal("v1 hash should not cover coinbase and extra data")
+	}
+	if diffs := DiffGenesis(a, b); len(diffs) != 0 {
+		t.Fatalf("v1 genesis should not report coinbase and extra data, got %v", diffs)
+	}
+
+	b.Version = GenesisV2
+	if diffs := DiffGenesis(a, b); len(diffs) != 3 || diffs[1].Kind != DiffCoinBase || diffs[2].Kind != DiffExtraData {
+		t.Fatalf("expected version, coinbase and extra data diffs, got %v", diffs)
+	}
+}
+
+func TestDiffGenesisAllocs(t *testing.T) {
+	a, err := TryLoadGenesis(testFilePath, "genesis_all")
+	if err != nil {
+		t.Fatalf("failed to load genesis file. cause: %v\n", err)
+	}
+
+	b := a.copy()
+	b.Alloc[0], b.Alloc[1] = b.Alloc[1], b.Alloc[0]
+	b.Alloc[2].Account = strings.ToLower(b.Alloc[2].Account)
+	b.Alloc = b.Alloc[:3]
+	b.Launcher = nil
+
+	kinds := make([]GenesisDiffKind, 0)
+	for _, d := range DiffGenesis(a, b) {
+		kinds = append(kinds, d.Kind)
+	}
+
+	want := []GenesisDiffKind{DiffAllocAccount, DiffAllocRemoved, DiffAllocReordered, DiffContractRemoved}
+	if len(kinds) != len(want) {
+		t.Fatalf("got kinds %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Errorf("kind %d is %q, want %q", i, kinds[i], want[i])
+		}
+	}
+
+	ha, _ := a.HexHash()
+	hb, _ := b.HexHash()
+	if ha == hb {
+		t.Errorf("reported differences should change the hash")
+	}
+}
@@ -0,0 +1,105 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Endpoint 为 peer 的一个网络地址，Host 为 IP 或主机名，IPv6 地址不带方括号
+type Endpoint struct {
+	Host string
+	Port uint16
+}
+
+// NewEndpoint 校验 host 和 port 并构造 Endpoint，host 可以是带方括号的 IPv6 地址
+func NewEndpoint(host, port string) (Endpoint, error) {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if err := validateHost(host); err != nil {
+		return Endpoint{}, err
+	}
+
+	n, err := parsePort(port)
+	if err != nil {
+		return Endpoint{}, err
+	}
+
+	return Endpoint{Host: host, Port: n}, nil
+}
+
+// ParseEndpoint 解析 host:port 形式的地址，IPv6 地址需写作 [::1]:1337
+func ParseEndpoint(addr string) (Endpoint, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("malformed address %q", addr)
+	}
+
+	return NewEndpoint(host, port)
+}
+
+// String 返回可以直接用于 net.Dial 的 host:port 字符串
+func (e Endpoint) String() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(int(e.Port)))
+}
+
+// IsIP 判断 Host 是否为 IP 地址
+func (e Endpoint) IsIP() bool {
+	return net.ParseIP(e.Host) != nil
+}
+
+// parsePort 将端口解析为 1 到 65535 之间的整数
+func parsePort(port string) (uint16, error) {
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid port %q", port)
+	}
+
+	return uint16(n), nil
+}
+
+// validateHost 检查 host 是否为 IP 地址或符合 RFC 1123 的主机名
+func validateHost(host string) error {
+	if len(host) == 0 {
+		return errors.New("host must not be empty")
+	}
+
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+
+	if len(host) > 253 {
+		return fmt.Errorf("invalid host %q", host)
+	}
+
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	for _, label := range labels {
+		if !isHostLabel(label) {
+			return fmt.Errorf("invalid host %q", host)
+		}
+	}
+
+	// 最后一段全为数字时只可能是写错的 IPv4 地址
+	if _, err := strconv.Atoi(labels[len(labels)-1]); err == nil {
+		return fmt.Errorf("invalid IP address %q", host)
+	}
+
+	return nil
+}
+
+func isHostLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+
+	for _, c := range label {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
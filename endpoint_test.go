@@ -0,0 +1,71 @@
+package conf
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestEndpoint(t *testing.T) {
+	cases := []struct {
+		addr string
+		want Endpoint
+	}{
+		{"127.0.0.1:1337", Endpoint{"127.0.0.1", 1337}},
+		{"[::1]:1337", Endpoint{"::1", 1337}},
+		{"node-0.bolaxy.io:8000", Endpoint{"node-0.bolaxy.io", 8000}},
+	}
+
+	for _, c := range cases {
+		e, err := ParseEndpoint(c.addr)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.addr, err)
+			continue
+		}
+		if e != c.want || e.String() != c.addr {
+			t.Errorf("%s: got %+v (%s)", c.addr, e, e)
+		}
+	}
+
+	for _, addr := range []string{"::1:1337", "127.0.0.1:0", "127.0.0.1:65536", "127.0.0.1:http", "-bad:80", "999.1.1.1:80", ":80"} {
+		if _, err := ParseEndpoint(addr); err == nil {
+			t.Errorf("%s should be rejected", addr)
+		}
+	}
+}
+
+func TestPeerEndpoints(t *testing.T) {
+	p := NewPeer(testPeers()[0].PubKeyHex, "::1", "node1", "8001", "1338")
+	if p.HttpAddress() != "[::1]:8001" || p.TcpAddress() != "[::1]:1338" {
+		t.Errorf("got %s and %s", p.HttpAddress(), p.TcpAddress())
+	}
+
+	p.Address = "[fe80::1]"
+	e, err := p.TcpEndpoint()
+	if err != nil || e != (Endpoint{"fe80::1", 1338}) || p.TcpAddress() != "[fe80::1]:1338" {
+		t.Errorf("bracketed IPv6 address: got %+v, %s (%v)", e, p.TcpAddress(), err)
+	}
+}
+
+func TestLoadConfigRejectsBadEndpoints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-endpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeWatchedConfig(t, dir, `httpport = "8001"`, `httpport = "80001"`, `tcpport = "1339"`, `tcpport = "x"`)
+	_, err = LoadConfig(dir)
+
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 2 ||
+		verrs[0].Path != "peerSet[1].httpport" || verrs[1].Path != "peerSet[2].tcpport" {
+		t.Fatalf("expected port errors, got %v", err)
+	}
+
+	writeWatchedConfig(t, dir, `address = "127.0.0.1"`, `address = "not a host"`)
+	if _, err = LoadConfig(dir); !errors.As(err, &verrs) || verrs[0].Path != "peerSet[0].address" {
+		t.Fatalf("expected address error, got %v", err)
+	}
+}
@@ -1,21 +1,32 @@
 package conf
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"sort"
 	"strings"
 
-	"github.com/spf13/viper"
-
 	"github.com/bolaxy/common/hexutil"
 	"github.com/bolaxy/crypto"
 	"github.com/bolaxy/rlp"
 )
 
+const (
+	// GenesisV1 为最初的编码，不包含 CoinBase 和 ExtraData，仅用于校验已有的链
+	GenesisV1 uint = 1
+	// GenesisV2 覆盖 Genesis 的全部字段，是未指定 version 时的默认编码
+	GenesisV2 uint = 2
+
+	// genesisV1Fields 为 v1 编码中 RLP 列表的元素个数，用于解码时区分版本
+	genesisV1Fields = 6
+)
+
 type Genesis struct {
+	Version           uint     `mapstructure:"version"`
 	CoinBase          string   `mapstructure:"coinbase"`
 	ChainID           string   `mapstructure:"chain-id"`
+	ExtraData         string   `mapstructure:"extra-data"`
 	ConsensusAccounts []string `mapstructure:"consensus-accounts"`
 	Alloc             []Alloc  `mapstructure:"alloc"`
 	Poa               *PoaMap  `mapstructure:"poa"`
@@ -48,6 +59,17 @@ type genesis struct {
 	Launcher          *poaMap  `rlp:"nil"`
 }
 
+type genesisV2 struct {
+	Version           uint
+	ChainID           string
+	CoinBase          string
+	ExtraData         string
+	ConsensusAccounts []string `rlp:"nil"`
+	Allocs            []alloc  `rlp:"nil"`
+	Poa               *poaMap  `rlp:"nil"`
+	Launcher          *poaMap  `rlp:"nil"`
+}
+
 type alloc struct {
 	Account     string
 	Balance     string
@@ -65,12 +87,30 @@ type poaMap struct {
 	Storage [][2]string `rlp:"nil"`
 }
 
+// EncodingVersion 返回计算哈希和 RLP 编码时使用的版本，未指定时为 GenesisV2
+func (g *Genesis) EncodingVersion() uint {
+	if g.Version == 0 {
+		return GenesisV2
+	}
+
+	return g.Version
+}
+
 func (g *Genesis) Hash() ([]byte, error) {
 	if g == nil {
 		return nil, nil
 	}
 
-	buf, err := EncodeRLPGenesis(g)
+	return g.HashVersion(g.EncodingVersion())
+}
+
+// HashVersion 使用指定版本的编码计算哈希，已有的链可以用 GenesisV1 校验
+func (g *Genesis) HashVersion(version uint) ([]byte, error) {
+	if g == nil {
+		return nil, nil
+	}
+
+	buf, err := EncodeRLPGenesisVersion(g, version)
 	if err != nil {
 		return nil, err
 	}
@@ -91,25 +131,72 @@ func (g *Genesis) HexHash() (string, error) {
 	return hexutil.Encode(hash), nil
 }
 
+// HexHashVersion 为 HashVersion 的十六进制形式
+func (g *Genesis) HexHashVersion(version uint) (string, error) {
+	if g == nil {
+		return "", nil
+	}
+
+	hash, err := g.HashVersion(version)
+	if err != nil {
+		return "", err
+	}
+
+	return hexutil.Encode(hash), nil
+}
+
 func (g *Genesis) EncodeRLP(w io.Writer) error {
-	genesis := &genesis{
-		ChainID:           g.ChainID,
-		ConsensusAccounts: g.ConsensusAccounts,
+	val, err := g.encoding(g.EncodingVersion())
+	if err != nil {
+		return err
 	}
 
+	return rlp.Encode(w, val)
+}
+
+// encoding 返回指定版本的 RLP 编码结构
+func (g *Genesis) encoding(version uint) (interface{}, error) {
+	var (
+		allocs   []alloc
+		poa      *poaMap
+		launcher *poaMap
+	)
+
 	if len(g.Alloc) > 0 {
-		genesis.Allocs = translateFromAlloc(g.Alloc)
+		allocs = translateFromAlloc(g.Alloc)
 	}
 
 	if g.Poa != nil {
-		genesis.Poa = translateFromPoaMap(g.Poa)
+		poa = translateFromPoaMap(g.Poa)
 	}
 
 	if g.Launcher != nil {
-		genesis.Launcher = translateFromPoaMap(g.Launcher)
+		launcher = translateFromPoaMap(g.Launcher)
 	}
 
-	return rlp.Encode(w, genesis)
+	switch version {
+	case GenesisV1:
+		return &genesis{
+			ChainID:           g.ChainID,
+			ConsensusAccounts: g.ConsensusAccounts,
+			Allocs:            allocs,
+			Poa:               poa,
+			Launcher:          launcher,
+		}, nil
+	case GenesisV2:
+		return &genesisV2{
+			Version:           GenesisV2,
+			ChainID:           g.ChainID,
+			CoinBase:          g.CoinBase,
+			ExtraData:         g.ExtraData,
+			ConsensusAccounts: g.ConsensusAccounts,
+			Allocs:            allocs,
+			Poa:               poa,
+			Launcher:          launcher,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported genesis version %d", version)
+	}
 }
 
 func translateFromAlloc(original []Alloc) []alloc {
@@ -158,25 +245,63 @@ func translateFromStorage(store map[string]string) (target [][2]string) {
 	return
 }
 
+// DecodeRLP 根据 RLP 列表的元素个数识别 v1 和 v2 编码
 func (g *Genesis) DecodeRLP(s *rlp.Stream) error {
-	var genesis genesis
-	if err := s.Decode(&genesis); err != nil {
+	raw, err := s.Raw()
+	if err != nil {
 		return err
 	}
 
-	g.ChainID = genesis.ChainID
-	g.ConsensusAccounts = genesis.ConsensusAccounts
+	content, _, err := rlp.SplitList(raw)
+	if err != nil {
+		return err
+	}
 
-	if len(genesis.Allocs) > 0 {
-		g.Alloc = translateToAlloc(genesis.Allocs)
+	n, err := rlp.CountValues(content)
+	if err != nil {
+		return err
 	}
 
-	if genesis.Poa != nil {
-		g.Poa = translateToPoaMap(genesis.Poa)
+	var (
+		allocs   []alloc
+		poa      *poaMap
+		launcher *poaMap
+	)
+
+	if n == genesisV1Fields {
+		var genesis genesis
+		if err := rlp.DecodeBytes(raw, &genesis); err != nil {
+			return err
+		}
+
+		g.Version = GenesisV1
+		g.ChainID = genesis.ChainID
+		g.ConsensusAccounts = genesis.ConsensusAccounts
+		allocs, poa, launcher = genesis.Allocs, genesis.Poa, genesis.Launcher
+	} else {
+		var genesis genesisV2
+		if err := rlp.DecodeBytes(raw, &genesis); err != nil {
+			return err
+		}
+
+		g.Version = genesis.Version
+		g.ChainID = genesis.ChainID
+		g.CoinBase = genesis.CoinBase
+		g.ExtraData = genesis.ExtraData
+		g.ConsensusAccounts = genesis.ConsensusAccounts
+		allocs, poa, launcher = genesis.Allocs, genesis.Poa, genesis.Launcher
 	}
 
-	if genesis.Launcher != nil {
-		g.Launcher = translateToPoaMap(genesis.Launcher)
+	if len(allocs) > 0 {
+		g.Alloc = translateToAlloc(allocs)
+	}
+
+	if poa != nil {
+		g.Poa = translateToPoaMap(poa)
+	}
+
+	if launcher != nil {
+		g.Launcher = translateToPoaMap(launcher)
 	}
 
 	return nil
@@ -230,6 +355,16 @@ func EncodeRLPGenesis(g *Genesis) ([]byte, error) {
 	return rlp.EncodeToBytes(g)
 }
 
+// EncodeRLPGenesisVersion 使用指定版本编码，不受 g.Version 影响
+func EncodeRLPGenesisVersion(g *Genesis, version uint) ([]byte, error) {
+	val, err := g.encoding(version)
+	if err != nil {
+		return nil, err
+	}
+
+	return rlp.EncodeToBytes(val)
+}
+
 func DecodeRLPGenesis(genesis []byte) (*Genesis, error) {
 	var g Genesis
 	err := rlp.DecodeBytes(genesis, &g)
@@ -245,19 +380,55 @@ func GetGenesisFromFile(filePath string) (*Genesis, error) {
 		return nil, err
 	}
 
-	viper.SetConfigFile(filePath)
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, err
-	}
-
 	var gensis Genesis
-	if err := viper.Unmarshal(&gensis); err != nil {
+	if err := NewFileLoader(filePath, &gensis).Load(); err != nil {
 		return nil, err
 	}
 
 	return &gensis, nil
 }
 
+// copy 深拷贝 Genesis
+func (g *Genesis) copy() *Genesis {
+	cpy := *g
+	cpy.ConsensusAccounts = append([]string(nil), g.ConsensusAccounts...)
+
+	if g.Alloc != nil {
+		cpy.Alloc = make([]Alloc, len(g.Alloc))
+		for i, a := range g.Alloc {
+			a.Storage = copyStorage(a.Storage)
+			cpy.Alloc[i] = a
+		}
+	}
+
+	if g.Poa != nil {
+		poa := *g.Poa
+		poa.Storage = copyStorage(poa.Storage)
+		cpy.Poa = &poa
+	}
+
+	if g.Launcher != nil {
+		launcher := *g.Launcher
+		launcher.Storage = copyStorage(launcher.Storage)
+		cpy.Launcher = &launcher
+	}
+
+	return &cpy
+}
+
+func copyStorage(storage map[string]string) map[string]string {
+	if storage == nil {
+		return nil
+	}
+
+	cpy := make(map[string]string, len(storage))
+	for k, v := range storage {
+		cpy[k] = v
+	}
+
+	return cpy
+}
+
 type alphabetic [][2]string
 
 func (list alphabetic) Len() int      { return len(list) }
@@ -0,0 +1,116 @@
+package conf
+
+import (
+	"testing"
+)
+
+var goldenGenesisHashes = []struct {
+	name string
+	v1   string
+	v2   string
+}{
+	{
+		"genesis",
+		"0x8461c06142402b6e6b977d51c7c239be8dcd09758683585dbb3683d0d5b0b211",
+		"0xa876b00bbc1beecdabf01a4fad65a9692c3162c376d149e19328db828e355e86",
+	},
+	{
+		"genesis_all",
+		"0x8f2414cffeaee89b7b86289bd8047e1c83ace14c8ef733c3ea1714396de7c685",
+		"0x550b578a4b3543f914f0fba9da8ab5538dd84e1dbbbfdd07fb4a4c26274e4386",
+	},
+	{
+		"genesis_no_launcher",
+		"0x9f1363927ef609cae02e5a6cbb00bc150d96d1ca909b2a225443c45dcb31780e",
+		"0x1efe1580d7eabdeb128ea6daa3a6f8d8a04d6199f96dddb5ea9b4aa68d1243c4",
+	},
+}
+
+func TestGenesisGoldenHashes(t *testing.T) {
+	for _, golden := range goldenGenesisHashes {
+		genesis, err := TryLoadGenesis(testFilePath, golden.name)
+		if err != nil {
+			t.Fatalf("failed to load genesis file. cause: %v\n", err)
+		}
+
+		v1, err := genesis.HexHashVersion(GenesisV1)
+		if err != nil {
+			t.Fatalf("%s: failed to get v1 hash. cause: %v\n", golden.name, err)
+		}
+		if v1 != golden.v1 {
+			t.Errorf("%s: v1 hash %s, want %s", golden.name, v1, golden.v1)
+		}
+
+		v2, err := genesis.HexHash()
+		if err != nil {
+			t.Fatalf("%s: failed to get hash. cause: %v\n", golden.name, err)
+		}
+		if v2 != golden.v2 {
+			t.Errorf("%s: v2 hash %s, want %s", golden.name, v2, golden.v2)
+		}
+
+		genesis.Version = GenesisV1
+		if h, _ := genesis.HexHash(); h != golden.v1 {
+			t.Errorf("%s: version = 1 should select v1 hash, got %s", golden.name, h)
+		}
+	}
+}
+
+func TestGenesisHashCoversCoinBase(t *testing.T) {
+	a, err := TryLoadGenesis(testFilePath)
+	if err != nil {
+		t.Fatalf("failed to load genesis file. cause: %v\n", err)
+	}
+
+	b, _ := TryLoadGenesis(testFilePath)
+	b.CoinBase = "0x94DD016aD0DbcA14e42e4Ec2E57710F190D48e92"
+
+	ha, _ := a.HexHash()
+	hb, _ := b.HexHash()
+	if ha == hb {
+		t.Error("v2 hash does not cover coinbase")
+	}
+
+	b.CoinBase = a.CoinBase
+	b.ExtraData = "0x01"
+	hb, _ = b.HexHash()
+	if ha == hb {
+		t.Error("v2 hash does not cover extra-data")
+	}
+
+	v1a, _ := a.HexHashVersion(GenesisV1)
+	v1b, _ := b.HexHashVersion(GenesisV1)
+	if v1a != v1b {
+		t.Error("v1 hash must not change with extra-data")
+	}
+}
+
+func TestGenesisVersionRoundTrip(t *testing.T) {
+	for _, version := range []uint{GenesisV1, GenesisV2} {
+		genesis, err := TryLoadGenesis(testFilePath, "genesis_all")
+		if err != nil {
+			t.Fatalf("failed to load genesis file. cause: %v\n", err)
+		}
+		genesis.Version = version
+
+		buf, err := EncodeRLPGenesis(genesis)
+		if err != nil {
+			t.Fatalf("v%d: failed to encode genesis. cause: %v\n", version, err)
+		}
+
+		decoded, err := DecodeRLPGenesis(buf)
+		if err != nil {
+			t.Fatalf("v%d: failed to decode genesis. cause: %v\n", version, err)
+		}
+
+		if decoded.Version != version {
+			t.Errorf("v%d: decoded as version %d", version, decoded.Version)
+		}
+
+		h1, _ := genesis.HexHash()
+		h2, _ := decoded.HexHash()
+		if h1 != h2 {
+			t.Errorf("v%d: hash changed across RLP round trip", version)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"sort"
+)
+
+// GethGenesis 为 geth genesis.json 的格式。
+//
+// config.chainId 对应 ChainID，coinbase 对应 CoinBase，extraData 对应 ExtraData，
+// alloc 中的 balance、code、storage 对应 Alloc 中的同名字段。config 中的其他字段以及
+// nonce、gasLimit、difficulty 等区块头字段 bolaxy 不使用，导入时忽略，导出时不输出。
+//
+// geth 中没有对应概念的 Version、ConsensusAccounts、Alloc.Authorising、Poa 和 Launcher
+// 保存在扩展键 bolaxy 中，geth 会忽略该键。alloc 在 geth 中是无序的 map，扩展键中还记录了
+// alloc 的原始顺序，以及导出时补为 "0x0" 的空余额，以保证导入后 Genesis.Hash() 不变。不带扩展键导出时这些字段被丢弃，
+// 导入时 alloc 按地址排序。
+type GethGenesis struct {
+	Config    *GethChainConfig       `json:"config,omitempty"`
+	ExtraData string                 `json:"extraData,omitempty"`
+	Coinbase  string                 `json:"coinbase,omitempty"`
+	Alloc     map[string]GethAccount `json:"alloc"`
+	Bolaxy    *GethExtension         `json:"bolaxy,omitempty"`
+}
+
+type GethChainConfig struct {
+	ChainID *big.Int `json:"chainId"`
+}
+
+type GethAccount struct {
+	Balance string            `json:"balance"`
+	Code    string            `json:"code,omitempty"`
+	Storage map[string]string `json:"storage,omitempty"`
+}
+
+// GethExtension 保存 geth 格式中没有的 bolaxy 字段
+type GethExtension struct {
+	Version           uint          `json:"version,omitempty"`
+	ConsensusAccounts []string      `json:"consensusAccounts,omitempty"`
+	Authorising       []string      `json:"authorising,omitempty"`
+	EmptyBalance      []string      `json:"emptyBalance,omitempty"`
+	AllocOrder        []string      `json:"allocOrder,omitempty"`
+	Poa               *GethContract `json:"poa,omitempty"`
+	Launcher          *GethContract `json:"launcher,omitempty"`
+}
+
+type GethContract struct {
+	Address string            `json:"address"`
+	Balance string            `json:"balance,omitempty"`
+	Abi     string            `json:"abi,omitempty"`
+	SubAbi  string            `json:"subabi,omitempty"`
+	Code    string            `json:"code,omitempty"`
+	Storage map[string]string `json:"storage,omitempty"`
+}
+
+// ToGeth 将 Genesis 转换为 geth 格式，withExtensions 为 false 时丢弃 bolaxy 特有的字段
+func (g *Genesis) ToGeth(withExtensions bool) (*GethGenesis, error) {
+	gg := &GethGenesis{
+		ExtraData: g.ExtraData,
+		Coinbase:  g.CoinBase,
+		Alloc:     make(map[string]GethAccount, len(g.Alloc)),
+	}
+
+	if len(g.ChainID) > 0 {
+		chainID, ok := new(big.Int).SetString(g.ChainID, 10)
+		if !ok {
+			return nil, fmt.Errorf("chain-id %q is not a decimal number", g.ChainID)
+		}
+		gg.Config = &GethChainConfig{ChainID: chainID}
+	}
+
+	ext := &GethExtension{
+		Version:           g.Version,
+		ConsensusAccounts: g.ConsensusAccounts,
+	}
+
+	for _, a := range g.Alloc {
+		if _, ok := gg.Alloc[a.Account]; ok {
+			return nil, fmt.Errorf("duplicate alloc account %s", a.Account)
+		}
+
+		// geth 要求 balance 不为空
+		balance := a.Balance
+		if len(balance) == 0 {
+			balance = "0x0"
+			ext.EmptyBalance = append(ext.EmptyBalance, a.Account)
+		}
+
+		gg.Alloc[a.Account] = GethAccount{
+			Balance: balance,
+			Code:    a.Code,
+			Storage: a.Storage,
+		}
+
+		ext.AllocOrder = append(ext.AllocOrder, a.Account)
+		if a.Authorising {
+			ext.Authorising = append(ext.Authorising, a.Account)
+		}
+	}
+
+	if g.Poa != nil {
+		ext.Poa = toGethContract(g.Poa)
+	}
+
+	if g.Launcher != nil {
+		ext.Launcher = toGethContract(g.Launcher)
+	}
+
+	if withExtensions {
+		gg.Bolaxy = ext
+	}
+
+	return gg, nil
+}
+
+// ToGenesis 将 geth 格式转换为 Genesis
+func (gg *GethGenesis) ToGenesis() (*Genesis, error) {
+	g := &Genesis{
+		CoinBase:  gg.Coinbase,
+		ExtraData: gg.ExtraData,
+	}
+
+	if gg.Config != nil && gg.Config.ChainID != nil {
+		g.ChainID = gg.Config.ChainID.String()
+	}
+
+	ext := gg.Bolaxy
+	if ext == nil {
+		ext = &GethExtension{}
+	}
+
+	order := ext.AllocOrder
+	if len(order) != len(gg.Alloc) {
+		order = make([]string, 0, len(gg.Alloc))
+		for addr := range gg.Alloc {
+			order = append(order, addr)
+		}
+		sort.Strings(order)
+	}
+
+	authorising := make(map[string]bool, len(ext.Authorising))
+	for _, addr := range ext.Authorising {
+		authorising[addr] = true
+	}
+
+	emptyBalance := make(map[string]bool, len(ext.EmptyBalance))
+	for _, addr := range ext.EmptyBalance {
+		emptyBalance[addr] = true
+	}
+
+	for _, addr := range order {
+		account, ok := gg.Alloc[addr]
+		if !ok {
+			return nil, fmt.Errorf("bolaxy.allocOrder references unknown account %s", addr)
+		}
+
+		balance := account.Balance
+		if emptyBalance[addr] && balance == "0x0" {
+			balance = ""
+		}
+
+		g.Alloc = append(g.Alloc, Alloc{
+			Account:     addr,
+			Balance:     balance,
+			Code:        account.Code,
+			Storage:     account.Storage,
+			Authorising: authorising[addr],
+		})
+	}
+
+	g.Version = ext.Version
+	g.ConsensusAccounts = ext.ConsensusAccounts
+
+	if ext.Poa != nil {
+		g.Poa = ext.Poa.poaMap()
+	}
+
+	if ext.Launcher != nil {
+		g.Launcher = ext.Launcher.poaMap()
+	}
+
+	return g, nil
+}
+
+// WriteGethGenesis 以 geth genesis.json 格式写入 w
+func (g *Genesis) WriteGethGenesis(w io.Writer, withExtensions bool) error {
+	gg, err := g.ToGeth(withExtensions)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(gg)
+}
+
+// ReadGethGenesis 从 r 读取 geth genesis.json 并转换为 Genesis
+func ReadGethGenesis(r io.Reader) (*Genesis, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var gg GethGenesis
+	if err := json.Unmarshal(data, &gg); err != nil {
+		return nil, err
+	}
+
+	return gg.ToGenesis()
+}
+
+func toGethContract(p *PoaMap) *GethContract {
+	return &GethContract{
+		Address: p.Address,
+		Balance: p.Balance,
+		Abi:     p.Abi,
+		SubAbi:  p.SubAbi,
+		Code:    p.Code,
+		Storage: p.Storage,
+	}
+}
+
+func (c *GethContract) poaMap() *PoaMap {
+	return &PoaMap{
+		Address: c.Address,
+		Balance: c.Balance,
+		Abi:     c.Abi,
+		SubAbi:  c.SubAbi,
+		Code:    c.Code,
+		Storage: c.Storage,
+	}
+}
@@ -0,0 +1,138 @@
+package conf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGethGenesisRoundTrip(t *testing.T) {
+	for _, name := range genesisNames {
+		genesis, err := TryLoadGenesis(testFilePath, name)
+		if err != nil {
+			t.Fatalf("failed to load genesis file. cause: %v\n", err)
+		}
+
+		var buf bytes.Buffer
+		if err := genesis.WriteGethGenesis(&buf, true); err != nil {
+			t.Fatalf("%s: failed to export genesis. cause: %v\n", name, err)
+		}
+
+		imported, err := ReadGethGenesis(&buf)
+		if err != nil {
+			t.Fatalf("%s: failed to import genesis. cause: %v\n", name, err)
+		}
+
+		if err := imported.Validate(); err != nil {
+			t.Errorf("%s: imported genesis is invalid: %v", name, err)
+		}
+
+		h1, _ := genesis.HexHash()
+		h2, _ := imported.HexHash()
+		if h1 != h2 {
+			t.Errorf("%s: hash changed across geth round trip: %s != %s", name, h1, h2)
+		}
+	}
+}
+
+func TestGethGenesisEmptyBalance(t *testing.T) {
+	genesis, err := TryLoadGenesis(testFilePath, "genesis_all")
+	if err != nil {
+		t.Fatalf("failed to load genesis file. cause: %v\n", err)
+	}
+
+	// 只有代码的合约账户没有余额
+	genesis.Alloc = append(genesis.Alloc, Alloc{Account: "0x1000000000000000000000000000000000000001", Code: "0x6080"})
+
+	var buf bytes.Buffer
+	if err := genesis.WriteGethGenesis(&buf, true); err != nil {
+		t.Fatalf("failed to export genesis. cause: %v\n", err)
+	}
+	if !strings.Contains(buf.String(), `"balance": "0x0"`) {
+		t.Fatal("empty balance should be exported as 0x0 for geth")
+	}
+
+	imported, err := ReadGethGenesis(&buf)
+	if err != nil {
+		t.Fatalf("failed to import genesis. cause: %v\n", err)
+	}
+
+	if b := imported.Alloc[len(imported.Alloc)-1].Balance; b != "" {
+		t.Errorf("empty balance imported as %q", b)
+	}
+
+	h1, _ := genesis.HexHash()
+	h2, _ := imported.HexHash()
+	if h1 != h2 {
+		t.Errorf("hash changed across geth round trip: %s != %s", h1, h2)
+	}
+}
+
+func TestGethGenesisWithoutExtensions(t *testing.T) {
+	genesis, err := TryLoadGenesis(testFilePath, "genesis_all")
+	if err != nil {
+		t.Fatalf("failed to load genesis file. cause: %v\n", err)
+	}
+
+	var buf bytes.Buffer
+	if err := genesis.WriteGethGenesis(&buf, false); err != nil {
+		t.Fatalf("failed to export genesis. cause: %v\n", err)
+	}
+
+	if strings.Contains(buf.String(), "bolaxy") {
+		t.Fatal("extension key exported although disabled")
+	}
+
+	imported, err := ReadGethGenesis(&buf)
+	if err != nil {
+		t.Fatalf("failed to import genesis. cause: %v\n", err)
+	}
+
+	if imported.ChainID != genesis.ChainID || imported.CoinBase != genesis.CoinBase || imported.ExtraData != genesis.ExtraData {
+		t.Fatalf("header fields lost: %+v", imported)
+	}
+
+	if imported.Poa != nil || imported.Launcher != nil || len(imported.ConsensusAccounts) != 0 {
+		t.Fatal("bolaxy-only fields should be dropped")
+	}
+
+	if len(imported.Alloc) != len(genesis.Alloc) {
+		t.Fatalf("expected %d allocs, got %d", len(genesis.Alloc), len(imported.Alloc))
+	}
+
+	for i := 1; i < len(imported.Alloc); i++ {
+		if imported.Alloc[i-1].Account > imported.Alloc[i].Account {
+			t.Fatal("allocs without extension should be sorted by address")
+		}
+	}
+}
+
+func TestReadGethGenesis(t *testing.T) {
+	const data = `{
+  "config": {"chainId": 15, "homesteadBlock": 0},
+  "difficulty": "0x400",
+  "gasLimit": "0x2fefd8",
+  "coinbase": "0x51BaAb5243DB87CbEd2beBebAD825255E5061f4D",
+  "alloc": {
+    "0x94DD016aD0DbcA14e42e4Ec2E57710F190D48e92": {"balance": "0x3635c9adc5dea00000"},
+    "0x51BaAb5243DB87CbEd2beBebAD825255E5061f4D": {"balance": "1000", "code": "0x6080"}
+  }
+}`
+
+	genesis, err := ReadGethGenesis(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to import genesis. cause: %v\n", err)
+	}
+
+	if genesis.ChainID != "15" || len(genesis.Alloc) != 2 {
+		t.Fatalf("unexpected genesis: %+v", genesis)
+	}
+
+	if genesis.Alloc[0].Account != "0x51BaAb5243DB87CbEd2beBebAD825255E5061f4D" || genesis.Alloc[0].Code != "0x6080" {
+		t.Fatalf("unexpected alloc: %+v", genesis.Alloc[0])
+	}
+
+	if err := genesis.Validate(); err != nil {
+		t.Fatalf("imported genesis is invalid: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// peerSetHistoryFile 为 PeerSetHistory 在 DataCnf.DataDir 下的文件名
+const peerSetHistoryFile = "peerset_history.json"
+
+var (
+	PeerSetNotFound = errors.New("no peer set for round")
+	RoundOutOfOrder = errors.New("round out of order")
+)
+
+// PeerSetEntry 记录从 Round 开始生效的 PeerSet，Hex 为添加时 PeerSet.Hex() 的值
+type PeerSetEntry struct {
+	Round   int     `json:"round"`
+	Hex     string  `json:"hex"`
+	Peers   []*Peer `json:"peers"`
+	peerSet *PeerSet
+}
+
+// PeerSet 返回该记录对应的 PeerSet
+func (e *PeerSetEntry) PeerSet() *PeerSet {
+	return e.peerSet
+}
+
+// PeerSetHistory 按生效的共识轮次记录 PeerSet 的变化，可以并发使用
+type PeerSetHistory struct {
+	mu      sync.RWMutex
+	entries []*PeerSetEntry
+}
+
+func NewPeerSetHistory() *PeerSetHistory {
+	return &PeerSetHistory{}
+}
+
+// Add 记录从 round 开始生效的 peerSet，round 必须大于已有的最后一个轮次
+func (h *PeerSetHistory) Add(round int, peerSet *PeerSet) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if n := len(h.entries); n > 0 && round <= h.entries[n-1].Round {
+		return fmt.Errorf("%w: %d is not after %d", RoundOutOfOrder, round, h.entries[n-1].Round)
+	}
+
+	h.entries = append(h.entries, &PeerSetEntry{
+		Round:   round,
+		Hex:     peerSet.Hex(),
+		Peers:   peerSet.Peers,
+		peerSet: peerSet,
+	})
+
+	return nil
+}
+
+// GetPeerSet 返回在 round 时生效的 PeerSet，即 Round 不大于 round 的最后一条记录
+func (h *PeerSetHistory) GetPeerSet(round int) (*PeerSet, error) {
+	e, err := h.Entry(round)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.peerSet, nil
+}
+
+// Entry 返回在 round 时生效的记录
+func (h *PeerSetHistory) Entry(round int) (*PeerSetEntry, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	i := sort.Search(len(h.entries), func(i int) bool { return h.entries[i].Round > round })
+	if i == 0 {
+		return nil, fmt.Errorf("%w %d", PeerSetNotFound, round)
+	}
+
+	return h.entries[i-1], nil
+}
+
+// Latest 返回最后添加的记录，没有记录时返回 nil
+func (h *PeerSetHistory) Latest() *PeerSetEntry {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(h.entries) == 0 {
+		return nil
+	}
+
+	return h.entries[len(h.entries)-1]
+}
+
+// Rounds 返回所有记录的生效轮次，按升序排列
+func (h *PeerSetHistory) Rounds() []int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	rounds := make([]int, 0, len(h.entries))
+	for _, e := range h.entries {
+		rounds = append(rounds, e.Round)
+	}
+
+	return rounds
+}
+
+// Rollback 删除 round 之后生效的记录，返回删除的条数
+func (h *PeerSetHistory) Rollback(round int) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i := sort.Search(len(h.entries), func(i int) bool { return h.entries[i].Round > round })
+	removed := len(h.entries) - i
+	h.entries = h.entries[:i:i]
+
+	return removed
+}
+
+// MarshalJSON 将记录编码为按轮次升序排列的列表
+func (h *PeerSetHistory) MarshalJSON() ([]byte, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return json.Marshal(h.entries)
+}
+
+// UnmarshalJSON 解码记录并由 Peers 重建 PeerSet，重建后的 Hex 必须与记录中的一致
+func (h *PeerSetHistory) UnmarshalJSON(data []byte) error {
+	var entries []*PeerSetEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	for i, e := range entries {
+		if i > 0 && e.Round <= entries[i-1].Round {
+			return fmt.Errorf("%w: %d is not after %d", RoundOutOfOrder, e.Round, entries[i-1].Round)
+		}
+
+		e.peerSet = NewPeerSet(e.Peers)
+		if hex := e.peerSet.Hex(); hex != e.Hex {
+			return fmt.Errorf("peer set of round %d has hash %s, recorded %s", e.Round, hex, e.Hex)
+		}
+		e.Peers = e.peerSet.Peers
+	}
+
+	h.mu.Lock()
+	h.entries = entries
+	h.mu.Unlock()
+
+	return nil
+}
+
+// Save 将历史写入 path，先写入临时文件再重命名，避免留下不完整的文件
+func (h *PeerSetHistory) Save(path string) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// LoadPeerSetHistory 从 path 读取历史，文件不存在时返回空的历史
+func LoadPeerSetHistory(path string) (*PeerSetHistory, error) {
+	h := NewPeerSetHistory()
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// GetPeerSetHistoryFile 返回 PeerSetHistory 在 DataCnf.DataDir 下的路径
+func (cnf *Config) GetPeerSetHistoryFile() string {
+	return filepath.Join(cnf.DataCnf.DataDir, peerSetHistoryFile)
+}
+
+// LoadPeerSetHistory 读取 DataCnf.DataDir 下的 PeerSetHistory
+func (cnf *Config) LoadPeerSetHistory() (*PeerSetHistory, error) {
+	return LoadPeerSetHistory(cnf.GetPeerSetHistoryFile())
+}
+
+// SavePeerSetHistory 将 h 写入 DataCnf.DataDir
+func (cnf *Config) SavePeerSetHistory(h *PeerSetHistory) error {
+	return h.Save(cnf.GetPeerSetHistoryFile())
+}
@@ -0,0 +1,93 @@
+package conf
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPeerSetHistory(t *testing.T) {
+	peers := randomPeers(t, 4)
+	sets := []*PeerSet{NewPeerSet(peers[:3])}
+	sets = append(sets, sets[0].WithNewPeer(peers[3]))
+	sets = append(sets, sets[1].WithRemovedPeer(peers[0]))
+
+	h := NewPeerSetHistory()
+	for i, round := range []int{0, 10, 25} {
+		if err := h.Add(round, sets[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := h.Add(25, sets[0]); !errors.Is(err, RoundOutOfOrder) {
+		t.Errorf("expected RoundOutOfOrder, got %v", err)
+	}
+
+	for round, want := range map[int]*PeerSet{0: sets[0], 9: sets[0], 10: sets[1], 24: sets[1], 100: sets[2]} {
+		ps, err := h.GetPeerSet(round)
+		if err != nil || ps.Hex() != want.Hex() {
+			t.Errorf("round %d: got %v (%v), want %s", round, ps, err, want.Hex())
+		}
+	}
+
+	if _, err := h.GetPeerSet(-1); !errors.Is(err, PeerSetNotFound) {
+		t.Errorf("expected PeerSetNotFound, got %v", err)
+	}
+
+	if n := h.Rollback(10); n != 1 || h.Latest().Hex != sets[1].Hex() {
+		t.Fatalf("rollback removed %d entries, latest is %s", n, h.Latest().Hex)
+	}
+	if err := h.Add(20, sets[2]); err != nil {
+		t.Fatalf("should add after rollback: %v", err)
+	}
+	if rounds := h.Rounds(); len(rounds) != 3 || rounds[2] != 20 {
+		t.Errorf("unexpected rounds %v", rounds)
+	}
+}
+
+func TestPeerSetHistoryPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cnf := DefaultConfig()
+	cnf.DataCnf.DataDir = dir
+
+	h, err := cnf.LoadPeerSetHistory()
+	if err != nil || h.Latest() != nil {
+		t.Fatalf("missing file should give an empty history, got %v (%v)", h.Latest(), err)
+	}
+
+	ps := NewPeerSet(testPeers())
+	if err := h.Add(5, ps); err != nil {
+		t.Fatal(err)
+	}
+	if err := cnf.SavePeerSetHistory(h); err != nil {
+		t.Fatalf("failed to save history. cause: %v\n", err)
+	}
+
+	loaded, err := cnf.LoadPeerSetHistory()
+	if err != nil {
+		t.Fatalf("failed to load history. cause: %v\n", err)
+	}
+	got, err := loaded.GetPeerSet(7)
+	if err != nil || got.Hex() != ps.Hex() || got.Len() != ps.Len() {
+		t.Fatalf("loaded history does not match, got %v (%v)", got, err)
+	}
+
+	// 篡改后的记录与其哈希不一致
+	path := cnf.GetPeerSetHistoryFile()
+	data, _ := ioutil.ReadFile(path)
+	pubKey := testPeers()[0].PubKeyHex
+	data = []byte(strings.Replace(string(data), pubKey, pubKey[:len(pubKey)-2]+"00", 1))
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cnf.LoadPeerSetHistory(); err == nil {
+		t.Error("tampered history should fail to load")
+	}
+}
@@ -0,0 +1,488 @@
+package conf
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/bolaxy/common/hexutil"
+	"github.com/bolaxy/crypto"
+)
+
+const (
+	keyHeaderKDF = "scrypt"
+	keyPBKDF2    = "pbkdf2"
+	keyCipher    = "aes-128-ctr"
+	keyVersion   = 3
+)
+
+var (
+	KeyNotFound      = errors.New("keystore not found")
+	PasswordNotFound = errors.New("password file not found")
+	WrongPassword    = errors.New("could not decrypt key with given password")
+	KeyMismatch      = errors.New("key does not match self peer")
+)
+
+// KeyError 描述载入节点私钥时发生的错误，Err 为上面定义的错误之一或底层错误
+type KeyError struct {
+	Path string
+	Err  error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+type encryptedKeyJSONV3 struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+	ID      string     `json:"id"`
+	Version int        `json:"version"`
+}
+
+type cryptoJSON struct {
+	Cipher       string                 `json:"cipher"`
+	CipherText   string                 `json:"ciphertext"`
+	CipherParams cipherparamsJSON       `json:"cipherparams"`
+	KDF          string                 `json:"kdf"`
+	KDFParams    map[string]interface{} `json:"kdfparams"`
+	MAC          string                 `json:"mac"`
+}
+
+type cipherparamsJSON struct {
+	IV string `json:"iv"`
+}
+
+// DecryptKey 使用 password 解密 v3 格式(scrypt/pbkdf2)的 keystore 数据
+func DecryptKey(keyjson []byte, password string) (*ecdsa.PrivateKey, error) {
+	var k encryptedKeyJSONV3
+	if err := json.Unmarshal(keyjson, &k); err != nil {
+		return nil, err
+	}
+
+	if k.Version != keyVersion {
+		return nil, fmt.Errorf("keystore version not supported: %v", k.Version)
+	}
+
+	if k.Crypto.Cipher != keyCipher {
+		return nil, fmt.Errorf("cipher not supported: %v", k.Crypto.Cipher)
+	}
+
+	mac, err := hex.DecodeString(k.Crypto.MAC)
+	if err != nil {
+		return nil, err
+	}
+
+	iv, err := hex.DecodeString(k.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid IV length %d, need %d", len(iv), aes.BlockSize)
+	}
+
+	cipherText, err := hex.DecodeString(k.Crypto.CipherText)
+	if err != nil {
+		return nil, err
+	}
+
+	derivedKey, err := getKDFKey(k.Crypto, password)
+	if err != nil {
+		return nil, err
+	}
+
+	calculatedMAC := crypto.Keccak256(derivedKey[16:32], cipherText)
+	if !bytes.Equal(calculatedMAC, mac) {
+		return nil, WrongPassword
+	}
+
+	plainText, err := aesCTRXOR(derivedKey[:16], cipherText, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.ToECDSA(plainText)
+}
+
+func getKDFKey(cryptoJSON cryptoJSON, password string) ([]byte, error) {
+	authArray := []byte(password)
+	salt, err := hex.DecodeString(paramString(cryptoJSON.KDFParams["salt"]))
+	if err != nil {
+		return nil, err
+	}
+	dkLen := paramInt(cryptoJSON.KDFParams["dklen"])
+	// 派生密钥的前 16 字节用于解密，后 16 字节用于计算 MAC
+	if dkLen < 32 {
+		return nil, fmt.Errorf("derived key too short: dklen %d, need at least 32", dkLen)
+	}
+
+	switch cryptoJSON.KDF {
+	case keyHeaderKDF:
+		n := paramInt(cryptoJSON.KDFParams["n"])
+		r := paramInt(cryptoJSON.KDFParams["r"])
+		p := paramInt(cryptoJSON.KDFParams["p"])
+		if n <= 0 || r <= 0 || p <= 0 {
+			return nil, fmt.Errorf("invalid scrypt params: n %d, r %d, p %d", n, r, p)
+		}
+		return scrypt.Key(authArray, salt, n, r, p, dkLen)
+	case keyPBKDF2:
+		c := paramInt(cryptoJSON.KDFParams["c"])
+		if c <= 0 {
+			return nil, fmt.Errorf("invalid PBKDF2 iteration count %d", c)
+		}
+		prf := paramString(cryptoJSON.KDFParams["prf"])
+		if prf != "hmac-sha256" {
+			return nil, fmt.Errorf("unsupported PBKDF2 PRF: %s", prf)
+		}
+		return pbkdf2.Key(authArray, salt, c, dkLen, sha256.New), nil
+	}
+
+	return nil, fmt.Errorf("unsupported KDF: %s", cryptoJSON.KDF)
+}
+
+func paramInt(v interface{}) int {
+	f, _ := v.(float64)
+	return int(f)
+}
+
+func paramString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func aesCTRXOR(key, inText, iv []byte) ([]byte, error) {
+	aesBlock, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := cipher.NewCTR(aesBlock, iv)
+	outText := make([]byte, len(inText))
+	stream.XORKeyStream(outText, inText)
+	return outText, nil
+}
+
+// ReadPassword 读取密码文件，忽略结尾的换行符
+func ReadPassword(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", &KeyError{Path: path, Err: PasswordNotFound}
+		}
+		return "", &KeyError{Path: path, Err: err}
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// keyFiles 返回 keystore 路径下的候选文件。path 可以是单个文件，也可以是目录
+func keyFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		files = append(files, filepath.Join(path, e.Name()))
+	}
+
+	return files, nil
+}
+
+// LoadKey 从 keystore 中解密节点私钥。keystore 为目录时，若提供了 self，
+// 优先选择地址与 self 公钥相符的文件，否则要求目录中只有一个文件。
+// 解密出的公钥必须与 self.PubKeyHex 一致
+func LoadKey(keystore, pwdFile string, self *Peer) (*ecdsa.PrivateKey, error) {
+	files, err := keyFiles(keystore)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &KeyError{Path: keystore, Err: KeyNotFound}
+		}
+		return nil, &KeyError{Path: keystore, Err: err}
+	}
+
+	if len(files) == 0 {
+		return nil, &KeyError{Path: keystore, Err: KeyNotFound}
+	}
+
+	password, err := ReadPassword(pwdFile)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := pickKeyFile(files, self)
+	if err != nil {
+		return nil, &KeyError{Path: keystore, Err: err}
+	}
+
+	keyjson, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, &KeyError{Path: file, Err: err}
+	}
+
+	key, err := DecryptKey(keyjson, password)
+	if err != nil {
+		return nil, &KeyError{Path: file, Err: err}
+	}
+
+	if self != nil && !bytes.Equal(crypto.FromECDSAPub(&key.PublicKey), self.PubKeyBytes()) {
+		return nil, &KeyError{Path: file, Err: KeyMismatch}
+	}
+
+	return key, nil
+}
+
+func pickKeyFile(files []string, self *Peer) (string, error) {
+	if len(files) == 1 {
+		return files[0], nil
+	}
+
+	if self != nil {
+		account, err := self.AccountAddress()
+		if err == nil {
+			addr := strings.ToLower(hex.EncodeToString(account.Bytes()))
+			for _, f := range files {
+				if keyFileAddress(f) == addr {
+					return f, nil
+				}
+			}
+		}
+	}
+
+	return "", KeyNotFound
+}
+
+func keyFileAddress(file string) string {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+
+	var k struct {
+		Address string `json:"address"`
+	}
+	if err := json.Unmarshal(data, &k); err != nil {
+		return ""
+	}
+
+	return strings.ToLower(strings.TrimPrefix(k.Address, "0x"))
+}
+
+const (
+	// StandardScryptN 和 StandardScryptP 为 geth 默认的 scrypt 参数
+	StandardScryptN = 1 << 18
+	StandardScryptP = 1
+
+	// LightScryptN 和 LightScryptP 用于测试或资源受限的环境
+	LightScryptN = 1 << 12
+	LightScryptP = 6
+
+	scryptR     = 8
+	scryptDKLen = 32
+
+	archiveDir = "archive"
+)
+
+// EncryptKey 使用 password 将私钥加密为 v3 格式的 keystore 数据
+func EncryptKey(key *ecdsa.PrivateKey, password string, scryptN, scryptP int) ([]byte, error) {
+	salt := crypto.GetEntropyCSPRNG(32)
+	derivedKey, err := scrypt.Key([]byte(password), salt, scryptN, scryptR, scryptP, scryptDKLen)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := crypto.GetEntropyCSPRNG(aes.BlockSize)
+	cipherText, err := aesCTRXOR(derivedKey[:16], crypto.FromECDSA(key), iv)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := crypto.Keccak256(derivedKey[16:32], cipherText)
+
+	k := encryptedKeyJSONV3{
+		Address: hex.EncodeToString(crypto.PubkeyToAddress(key.PublicKey).Bytes()),
+		Crypto: cryptoJSON{
+			Cipher:     keyCipher,
+			CipherText: hex.EncodeToString(cipherText),
+			CipherParams: cipherparamsJSON{
+				IV: hex.EncodeToString(iv),
+			},
+			KDF: keyHeaderKDF,
+			KDFParams: map[string]interface{}{
+				"n":     scryptN,
+				"r":     scryptR,
+				"p":     scryptP,
+				"dklen": scryptDKLen,
+				"salt":  hex.EncodeToString(salt),
+			},
+			MAC: hex.EncodeToString(mac),
+		},
+		ID:      newUUID(),
+		Version: keyVersion,
+	}
+
+	return json.MarshalIndent(k, "", "  ")
+}
+
+// newUUID 生成随机(v4)UUID，作为 keystore 文件的 id
+func newUUID() string {
+	u := crypto.GetEntropyCSPRNG(16)
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// PubKeyHex 返回公钥的大写十六进制形式，与 NewPeer 处理后的 Peer.PubKeyHex 一致
+func PubKeyHex(pub *ecdsa.PublicKey) string {
+	return strings.ToUpper(hexutil.Encode(crypto.FromECDSAPub(pub)))
+}
+
+// Keystore 管理某个目录下的节点私钥文件
+type Keystore struct {
+	Dir     string
+	ScryptN int
+	ScryptP int
+}
+
+// NewKeystore 创建使用标准 scrypt 参数的 Keystore
+func NewKeystore(dir string) *Keystore {
+	return &Keystore{
+		Dir:     dir,
+		ScryptN: StandardScryptN,
+		ScryptP: StandardScryptP,
+	}
+}
+
+// Generate 生成新的 secp256k1 私钥并加密保存，返回私钥和文件路径
+func (ks *Keystore) Generate(password string) (*ecdsa.PrivateKey, string, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	path, err := ks.Store(key, password)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, path, nil
+}
+
+// Import 导入十六进制形式(可带 0x 前缀)的原始私钥并加密保存
+func (ks *Keystore) Import(hexkey, password string) (*ecdsa.PrivateKey, string, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimPrefix(hexkey, "0x"), "0X"))
+	if err != nil {
+		return nil, "", err
+	}
+
+	path, err := ks.Store(key, password)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, path, nil
+}
+
+// Store 将私钥加密写入 keystore 目录，文件权限为 0600
+func (ks *Keystore) Store(key *ecdsa.PrivateKey, password string) (string, error) {
+	keyjson, err := EncryptKey(key, password, ks.ScryptN, ks.ScryptP)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(ks.Dir, 0700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(ks.Dir, keyFileName(key))
+	if err := ioutil.WriteFile(path, keyjson, 0600); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// Load 解密 keystore 目录中的私钥，参见 LoadKey
+func (ks *Keystore) Load(pwdFile string, self *Peer) (*ecdsa.PrivateKey, error) {
+	return LoadKey(ks.Dir, pwdFile, self)
+}
+
+// Export 解密私钥并返回可直接用于 Peer.PubKeyHex 的公钥
+func (ks *Keystore) Export(pwdFile string) (string, error) {
+	key, err := ks.Load(pwdFile, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return PubKeyHex(&key.PublicKey), nil
+}
+
+// Rotate 生成新的私钥替换当前私钥。新私钥写入成功后，旧的 keystore 文件才被移动到
+// archive 子目录中，生成或写入失败时 keystore 保持不变
+func (ks *Keystore) Rotate(password string) (*ecdsa.PrivateKey, string, error) {
+	files, err := keyFiles(ks.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, "", err
+	}
+
+	key, path, err := ks.Generate(password)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(files) > 0 {
+		archive := filepath.Join(ks.Dir, archiveDir)
+		if err := os.MkdirAll(archive, 0700); err != nil {
+			return nil, "", err
+		}
+
+		for _, f := range files {
+			if f == path {
+				continue
+			}
+			if err := os.Rename(f, filepath.Join(archive, filepath.Base(f))); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	return key, path, nil
+}
+
+// keyFileName 按 geth 的方式命名 keystore 文件: UTC--<时间>--<地址>
+func keyFileName(key *ecdsa.PrivateKey) string {
+	ts := time.Now().UTC().Format("2006-01-02T15-04-05.000000000Z")
+	return fmt.Sprintf("UTC--%s--%s", ts, hex.EncodeToString(crypto.PubkeyToAddress(key.PublicKey).Bytes()))
+}
@@ -0,0 +1,261 @@
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	testKeystore = filepath.Join(testFilePath, "keystore")
+	testPwdFile  = filepath.Join(testFilePath, "password")
+)
+
+func testSelfPeer(t *testing.T, alias string) *Peer {
+	cnf, err := TryLoadConfig(testFilePath)
+	if err != nil {
+		t.Fatalf("failed to load config file. cause: %v\n", err)
+	}
+
+	return SelfPeer(alias, cnf.Peerlist)
+}
+
+func TestLoadKey(t *testing.T) {
+	self := testSelfPeer(t, "node0")
+	if _, err := LoadKey(testKeystore, testPwdFile, self); err != nil {
+		t.Fatalf("failed to load key. cause: %v\n", err)
+	}
+
+	if Key == nil {
+		t.Fatal("TryLoadConfig did not populate Key")
+	}
+}
+
+func TestLoadKeyErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wrongPwd := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(wrongPwd, []byte("wrong\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		keystore string
+		pwdFile  string
+		self     *Peer
+		want     error
+	}{
+		{"missing keystore", filepath.Join(dir, "keystore"), testPwdFile, nil, KeyNotFound},
+		{"missing password", testKeystore, filepath.Join(dir, "nothing"), nil, PasswordNotFound},
+		{"wrong password", testKeystore, wrongPwd, nil, WrongPassword},
+		{"mismatch", testKeystore, testPwdFile, testSelfPeer(t, "node1"), KeyMismatch},
+	}
+
+	for _, c := range cases {
+		_, err := LoadKey(c.keystore, c.pwdFile, c.self)
+		var keyErr *KeyError
+		if !errors.As(err, &keyErr) || !errors.Is(err, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, err)
+		}
+	}
+}
+
+func TestLoadKeyBadKDFParams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyjson, err := ioutil.ReadFile(filepath.Join(testKeystore, "key.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// dklen 过短、缺失或 KDF 参数不是正数时应返回错误而不是 panic
+	for i, params := range [][]string{
+		{`"dklen": 32`, `"dklen": 16`},
+		{`"dklen": 32`, `"dklen": null`},
+		{`"dklen": 32`, `"dklen": -1`},
+		{`"n": 4096`, `"n": -1`},
+		{`"r": 8`, `"r": 0`},
+		{`"p": 1`, `"p": -1`},
+		{`"kdf": "scrypt"`, `"kdf": "pbkdf2"`, `"n": 4096`, `"c": -1, "prf": "hmac-sha256"`},
+		{`"kdf": "scrypt"`, `"kdf": "pbkdf2"`, `"n": 4096`, `"c": 1, "prf": "hmac-sha256"`, `"dklen": 32`, `"dklen": -1`},
+	} {
+		keystore := filepath.Join(dir, fmt.Sprintf("key%d.json", i))
+		data := strings.NewReplacer(params...).Replace(string(keyjson))
+		if err := ioutil.WriteFile(keystore, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := LoadKey(keystore, testPwdFile, nil)
+		var keyErr *KeyError
+		if !errors.As(err, &keyErr) {
+			t.Errorf("%v: expected *KeyError, got %v", params, err)
+		}
+	}
+}
+
+func TestLoadKeyBadIV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyjson, err := ioutil.ReadFile(filepath.Join(testKeystore, "key.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// MAC 不覆盖 IV，长度错误的 IV 仍能通过 MAC 校验，应返回错误而不是 panic
+	var k encryptedKeyJSONV3
+	if err := json.Unmarshal(keyjson, &k); err != nil {
+		t.Fatal(err)
+	}
+	k.Crypto.CipherParams.IV = k.Crypto.CipherParams.IV[:16]
+	data, err := json.Marshal(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keystore := filepath.Join(dir, "key.json")
+	if err := ioutil.WriteFile(keystore, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadKey(keystore, testPwdFile, nil)
+	var keyErr *KeyError
+	if !errors.As(err, &keyErr) {
+		t.Errorf("expected *KeyError, got %v", err)
+	}
+}
+
+func TestKeystoreManagement(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pwdFile := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(pwdFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ks := NewKeystore(filepath.Join(dir, "keystore"))
+	ks.ScryptN, ks.ScryptP = LightScryptN, LightScryptP
+
+	key, path, err := ks.Generate("secret")
+	if err != nil {
+		t.Fatalf("failed to generate key. cause: %v\n", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("expected key file permissions 0600, got %o", info.Mode().Perm())
+	}
+
+	pub, err := ks.Export(pwdFile)
+	if err != nil {
+		t.Fatalf("failed to export key. cause: %v\n", err)
+	}
+	if pub != PubKeyHex(&key.PublicKey) {
+		t.Fatalf("exported public key %s does not match %s", pub, PubKeyHex(&key.PublicKey))
+	}
+
+	peer := NewPeer(pub, "127.0.0.1", "node", "8000", "1337")
+	if peer.PubKeyHex != pub {
+		t.Fatalf("exported public key is not in Peer format: %s", pub)
+	}
+
+	rotated, _, err := ks.Rotate("secret")
+	if err != nil {
+		t.Fatalf("failed to rotate key. cause: %v\n", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(ks.Dir, archiveDir, filepath.Base(path))); err != nil {
+		t.Fatalf("old key was not archived. cause: %v\n", err)
+	}
+
+	loaded, err := ks.Load(pwdFile, nil)
+	if err != nil {
+		t.Fatalf("failed to load rotated key. cause: %v\n", err)
+	}
+	if loaded.D.Cmp(rotated.D) != 0 {
+		t.Fatal("loaded key is not the rotated key")
+	}
+
+	if _, err := ks.Load(pwdFile, peer); !errors.Is(err, KeyMismatch) {
+		t.Fatalf("expected %v after rotation, got %v", KeyMismatch, err)
+	}
+}
+
+func TestKeystoreRotateFailureKeepsKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks := NewKeystore(dir)
+	ks.ScryptN, ks.ScryptP = LightScryptN, LightScryptP
+	_, path, err := ks.Generate("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// N 不是 2 的幂，scrypt 失败
+	ks.ScryptN = 3
+	if _, _, err := ks.Rotate("secret"); err == nil {
+		t.Fatal("rotation with invalid scrypt parameters should fail")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("current key should stay in place after a failed rotation. cause: %v\n", err)
+	}
+}
+
+func TestKeystoreImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks := NewKeystore(dir)
+	ks.ScryptN, ks.ScryptP = LightScryptN, LightScryptP
+
+	hexkey := "0x289c2857d4598e37fb9647507e47a309d6133539bf21a8b9cb6df88fd5232032"
+	key, path, err := ks.Import(hexkey, "secret")
+	if err != nil {
+		t.Fatalf("failed to import key. cause: %v\n", err)
+	}
+
+	keyjson, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := DecryptKey(keyjson, "secret")
+	if err != nil {
+		t.Fatalf("failed to decrypt imported key. cause: %v\n", err)
+	}
+	if decrypted.D.Cmp(key.D) != 0 {
+		t.Fatal("decrypted key differs from imported key")
+	}
+}
@@ -2,9 +2,11 @@ package conf
 
 import (
 	"errors"
-
+	"fmt"
+	"strings"
 
 	"github.com/bolaxy/common"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -19,82 +21,304 @@ var (
 	FileNotFound = errors.New("file not found")
 )
 
+// Loader 使用独立的 viper 实例载入配置文件，多个 Loader 之间互不影响，
+// 可以在同一进程中并发载入多份配置
+type Loader struct {
+	name    string
+	file    string
+	paths   []string
+	sources []string
+	target  interface{}
+	viper   *viper.Viper
+
+	envPrefix string
+	flags     *pflag.FlagSet
+}
+
+// NewLoader 创建按名称在 paths 中依次查找配置文件的 Loader，载入结果写入 target
+func NewLoader(name string, target interface{}, paths ...string) *Loader {
+	return &Loader{
+		name:    name,
+		paths:   paths,
+		sources: make([]string, len(paths)),
+		target:  target,
+	}
+}
+
+// NewFileLoader 创建直接载入 file 的 Loader
+func NewFileLoader(file string, target interface{}) *Loader {
+	return &Loader{
+		file:   file,
+		target: target,
+	}
+}
+
+// AddPath 追加一个查找路径
+func (l *Loader) AddPath(path string) {
+	l.addPath("", path)
+}
+
+// addPath 追加一个查找路径，source 描述路径的来源，用于 NotFoundError
+func (l *Loader) addPath(source, path string) {
+	l.paths = append(l.paths, path)
+	l.sources = append(l.sources, source)
+}
+
+// SearchPaths 返回 Loader 的查找路径
+func (l *Loader) SearchPaths() []string {
+	return l.paths
+}
+
+// Viper 返回最近一次成功载入时使用的 viper 实例
+func (l *Loader) Viper() *viper.Viper {
+	return l.viper
+}
+
+// Source 返回最近一次成功载入的配置文件路径
+func (l *Loader) Source() string {
+	if l.viper == nil {
+		return ""
+	}
+
+	return l.viper.ConfigFileUsed()
+}
+
+// SearchAttempt 记录在一个查找路径中载入配置文件失败的原因
+type SearchAttempt struct {
+	Source string
+	Dir    string
+	Err    error
+}
+
+// NotFoundError 表示在所有查找路径中都没有找到可用的配置文件
+type NotFoundError struct {
+	Name     string
+	Exts     []string
+	Attempts []SearchAttempt
+}
+
+func (e *NotFoundError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "config file %q not found (extensions: %s)", e.Name, strings.Join(e.Exts, ", "))
+	for _, a := range e.Attempts {
+		if len(a.Source) > 0 {
+			fmt.Fprintf(&b, "\n  %s (%s): %v", a.Source, a.Dir, a.Err)
+		} else {
+			fmt.Fprintf(&b, "\n  %s: %v", a.Dir, a.Err)
+		}
+	}
+
+	return b.String()
+}
+
+// Unwrap 使 errors.Is(err, FileNotFound) 成立
+func (e *NotFoundError) Unwrap() error {
+	return FileNotFound
+}
+
+// Load 依次尝试每个查找路径，直到找到配置文件为止。找到的配置文件无法解析时直接返回该错误，
+// 不再继续查找；所有路径中都没有配置文件时返回 *NotFoundError，其中记录了每个路径失败的原因
+func (l *Loader) Load() error {
+	if len(l.file) > 0 {
+		return l.loadFile()
+	}
+
+	notFound := &NotFoundError{
+		Name: l.name,
+		Exts: viper.SupportedExts,
+	}
+	for i, path := range l.paths {
+		err := l.load(path)
+		if err == nil {
+			return nil
+		}
+
+		var missing viper.ConfigFileNotFoundError
+		if len(path) > 0 && !errors.As(err, &missing) {
+			return err
+		}
+
+		notFound.Attempts = append(notFound.Attempts, SearchAttempt{
+			Source: l.sources[i],
+			Dir:    path,
+			Err:    err,
+		})
+	}
+
+	return notFound
+}
+
+func (l *Loader) load(path string) error {
+	if len(path) == 0 {
+		return errors.New("path not set")
+	}
+
+	v := viper.New()
+	v.SetConfigName(l.name)
+	v.AddConfigPath(path)
+	return l.read(v)
+}
+
+func (l *Loader) loadFile() error {
+	v := viper.New()
+	v.SetConfigFile(l.file)
+	return l.read(v)
+}
+
+func (l *Loader) read(v *viper.Viper) error {
+	if err := l.bindOverrides(v); err != nil {
+		return err
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+
+	if err := v.Unmarshal(l.target); err != nil {
+		return err
+	}
+
+	l.viper = v
+	return nil
+}
+
+// newSearchLoader 创建按名称查找配置文件的 Loader。显式提供路径时只查找该路径，否则按
+// ${HOME}/.bolaxy > $ENV[BOLAXYDIR] > WORKDIR > EXE RUN PATH 的顺序查找
+func newSearchLoader(name string, target interface{}, filePath string) *Loader {
+	l := NewLoader(name, target)
+	if len(filePath) > 0 {
+		l.addPath("filePath", filePath)
+		return l
+	}
+
+	l.addPath("${HOME}/"+DefaultHomeBase, common.Home(DefaultHomeBase))
+	l.addPath("$"+DefaultEnv, common.Env(DefaultEnv))
+	l.addPath("WORKDIR", common.WorkDir())
+	l.addPath("EXE RUN PATH", common.ExeDir())
+	return l
+}
+
 // TryLoadConfig 载入配置文件。本函数可以被调用多次。
 // 如果传入的文件路径为空，会主动寻找可用配置。查找顺序为
 // filePath > ${HOME}/.bolaxy > $ENV[BOLAXYDIR] > WORKDIR > EXE RUN PATH
 // 显式提供配置文件路径，会直接处理，非显式提供配置文件路径，直到找到正确的配置文件为止
+// 载入结果写入 Global，并同步更新 Peers、Key 和 Logger。ValidateOnLoad 为 true 时会校验配置，
+// 已经载入 GensisData 时还会检查其共识账户与 peerSet 一致
+// 环境变量与 ConfigFlags 中的命令行参数可以覆盖配置文件，参见 EnvPrefix 和 RegisterFlags
+// 配置文件中缺少的配置项取 DefaultConfig() 中的值，不会沿用上一次载入的结果
 func TryLoadConfig(filePath string, cfgName ...string) (*Config, error) {
-	cname := configName
-	if len(cfgName) == 1 {
-		cname = cfgName[0]
+	cnf, err := LoadConfig(filePath, cfgName...)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := genericLoad(filePath, cname, Global); err != nil {
-		return nil, err
+	// 创世文件先于配置载入时，由这里检查共识账户
+	if ValidateOnLoad && GensisData != nil {
+		if err := cnf.CheckGenesis(GensisData); err != nil {
+			return nil, err
+		}
 	}
+
+	Global.replace(cnf)
+	Key = Global.GetKey()
 	Logger = Global.GetLogger()
 	return Global, nil
 }
 
-// TryLoadGenesis 载入创世配置文件。创世配置文件只在节点初始化时调用一次
-// 如果传入的文件路径为空，会主动寻找可用配置。查找顺序为
-// filePath > ${HOME}/.bolaxy > $ENV[BOLAXYDIR] > WORKDIR > EXE RUN PATH
-// 显式提供配置文件路径，会直接处理，非显式提供配置文件路径，直到找到正确的配置文件为止
-func TryLoadGenesis(filePath string, genName ...string) (*Genesis, error) {
-	gname := genesisName
-	if len(genName) == 1 {
-		gname = genName[0]
+// LoadConfig 与 TryLoadConfig 的查找规则相同，但返回一份独立的 Config，
+// 不修改 Global 及其他包级变量
+func LoadConfig(filePath string, cfgName ...string) (*Config, error) {
+	cname := configName
+	if len(cfgName) == 1 {
+		cname = cfgName[0]
 	}
 
-	return TryLoadGenesisWithName(filePath, gname)
+	cnf := DefaultConfig()
+	if err := loadConfig(configLoader(newSearchLoader(cname, cnf, filePath)), cnf); err != nil {
+		return nil, err
+	}
+
+	return cnf, nil
 }
 
-func TryLoadGenesisWithName(filePath, name string) (*Genesis, error) {
-	var genesis Genesis
-	if err := genericLoad(filePath, name, &genesis); err != nil {
-		return nil, err
+// configLoader 为载入 Config 的 Loader 绑定环境变量和 ConfigFlags
+func configLoader(l *Loader) *Loader {
+	l.AutomaticEnv(EnvPrefix)
+	if ConfigFlags != nil {
+		l.BindFlags(ConfigFlags)
 	}
 
-	return &genesis, nil
+	return l
 }
 
-func genericLoad(filePath, fileName string, rawVal interface{}) (err error) {
-	if len(filePath) > 0 {
-		return load(filePath, fileName, rawVal)
+// loadConfig 使用 l 载入 cnf，之后校验配置、构造 PeerSet 并解密节点私钥
+func loadConfig(l *Loader, cnf *Config) error {
+	if err := l.Load(); err != nil {
+		return err
 	}
-	if err = load(common.Home(DefaultHomeBase), fileName, rawVal); err == nil {
-		return nil
+	cnf.source = l.Source()
+	normalizePubKeys(cnf.Peerlist)
+
+	if ValidateOnLoad {
+		if err := cnf.Validate(); err != nil {
+			return err
+		}
 	}
 
-	if err = load(common.Env(DefaultEnv), fileName, rawVal); err == nil {
-		return nil
+	if err := cnf.SetPeers(NewPeerSet(cnf.Peerlist)); err != nil {
+		return err
 	}
 
-	if err = load(common.WorkDir(), fileName, rawVal); err == nil {
-		return nil
+	key, err := LoadKey(cnf.GetKeystore(), cnf.GetPwdFile(), cnf.SelfPeer())
+	if err != nil {
+		return err
 	}
+	cnf.key = key
 
-	if err = load(common.ExeDir(), fileName, rawVal); err == nil {
-		return nil
+	return nil
+}
+
+// TryLoadGenesis 载入创世配置文件。创世配置文件只在节点初始化时调用一次
+// 如果传入的文件路径为空，会主动寻找可用配置。查找顺序为
+// filePath > ${HOME}/.bolaxy > $ENV[BOLAXYDIR] > WORKDIR > EXE RUN PATH
+// 显式提供配置文件路径，会直接处理，非显式提供配置文件路径，直到找到正确的配置文件为止。
+// AttestationThreshold 不为 nil 时还要求足够的共识账户签名。载入结果的副本写入 GensisData
+func TryLoadGenesis(filePath string, genName ...string) (*Genesis, error) {
+	gname := genesisName
+	if len(genName) == 1 {
+		gname = genName[0]
 	}
 
-	return err
+	return TryLoadGenesisWithName(filePath, gname)
 }
 
-func load(filePath, fileName string, rawVal interface{}) error {
-	if len(filePath) == 0 {
-		return FileNotFound
+func TryLoadGenesisWithName(filePath, name string) (*Genesis, error) {
+	var genesis Genesis
+	l := newSearchLoader(name, &genesis, filePath)
+	if err := l.Load(); err != nil {
+		return nil, err
 	}
 
-	viper.SetConfigName(fileName)
-	viper.AddConfigPath(filePath)
-	if err := viper.ReadInConfig(); err != nil {
-		return err
+	if ValidateOnLoad {
+		if err := genesis.Validate(); err != nil {
+			return nil, err
+		}
+
+		// 配置已经载入时，共识账户必须与 peerSet 一致；否则由之后的 TryLoadConfig 检查
+		if Global.GetPeers() != nil {
+			if err := Global.CheckGenesis(&genesis); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	if err := viper.Unmarshal(rawVal); err != nil {
-		return err
+	if AttestationThreshold != nil {
+		if err := requireAttestations(l.Source(), &genesis); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	// 保存副本，调用方修改返回值不会影响 GensisData
+	GensisData = genesis.copy()
+	return &genesis, nil
 }
@@ -2,6 +2,11 @@ package conf
 
 import (
 	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
@@ -105,3 +110,118 @@ func TestDecodeConfig(t *testing.T) {
 	// fmt.Println("---:", Global.Eth.CacheSize)
 	spew.Dump(Global)
 }
+
+func TestLoaderIsolation(t *testing.T) {
+	var (
+		wg       sync.WaitGroup
+		configs  = make([]*Config, 4)
+		geneses  = make([]*Genesis, len(genesisNames))
+		errs     = make(chan error, len(configs)+len(geneses))
+		globalPL = Global.Peerlist
+	)
+
+	for i := range configs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cnf, err := LoadConfig(testFilePath)
+			if err != nil {
+				errs <- err
+				return
+			}
+			configs[i] = cnf
+		}(i)
+	}
+
+	for i, name := range genesisNames {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			g, err := TryLoadGenesis(testFilePath, name)
+			if err != nil {
+				errs <- err
+				return
+			}
+			geneses[i] = g
+		}(i, name)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("failed to load in parallel. cause: %v\n", err)
+	}
+
+	for i := 1; i < len(configs); i++ {
+		if configs[i] == configs[0] || configs[i].GetPeers() == configs[0].GetPeers() {
+			t.Fatal("independent configs share state")
+		}
+		if configs[i].GetPeers().Hex() != configs[0].GetPeers().Hex() {
+			t.Fatal("independent configs loaded different peer sets")
+		}
+	}
+
+	if geneses[0].Launcher == nil || geneses[2].Launcher != nil {
+		t.Fatal("genesis files leaked into each other")
+	}
+
+	if len(Global.Peerlist) != len(globalPL) {
+		t.Fatal("LoadConfig modified Global")
+	}
+}
+
+func TestNotFoundError(t *testing.T) {
+	os.Unsetenv(DefaultEnv)
+
+	_, err := LoadConfig("", "no-such-config")
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("expected NotFoundError, got %v", err)
+	}
+
+	if !errors.Is(err, FileNotFound) {
+		t.Fatal("NotFoundError should match FileNotFound")
+	}
+
+	if notFound.Name != "no-such-config" || len(notFound.Attempts) != 4 {
+		t.Fatalf("unexpected search report: %v", err)
+	}
+
+	if notFound.Attempts[1].Source != "$"+DefaultEnv || notFound.Attempts[1].Dir != "" {
+		t.Fatalf("expected unset $%s to be reported, got %+v", DefaultEnv, notFound.Attempts[1])
+	}
+}
+
+func TestParseErrorIsNotNotFound(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.toml"), []byte("self = \"node0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadConfig(dir)
+	if err == nil {
+		t.Fatal("expected parse error")
+	}
+
+	var notFound *NotFoundError
+	if errors.As(err, &notFound) || errors.Is(err, FileNotFound) {
+		t.Fatalf("parse error reported as not found: %v", err)
+	}
+}
+
+func TestConfigSource(t *testing.T) {
+	cnf, err := LoadConfig(testFilePath)
+	if err != nil {
+		t.Fatalf("failed to load config file. cause: %v\n", err)
+	}
+
+	want, _ := filepath.Abs(filepath.Join(testFilePath, "config.toml"))
+	if cnf.Source() != want {
+		t.Fatalf("unexpected config source %q", cnf.Source())
+	}
+}
@@ -0,0 +1,134 @@
+package conf
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix 为环境变量前缀。配置项 netcnf.tcp-timeout 对应的环境变量为
+// BOLAXY_NETCNF_TCP_TIMEOUT
+const EnvPrefix = "BOLAXY"
+
+// ConfigFlags 为 TryLoadConfig/LoadConfig 绑定的命令行参数，由 RegisterFlags 设置。
+// 配置项的优先级为: 命令行参数 > 环境变量 > 配置文件 > DefaultConfig()
+var ConfigFlags *pflag.FlagSet
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	envReplacer  = strings.NewReplacer(".", "_", "-", "_")
+)
+
+// configKey 描述一个可以被环境变量或命令行参数覆盖的配置项
+type configKey struct {
+	path  string
+	value reflect.Value
+}
+
+// configKeys 按 mapstructure 标签展开 val 中的标量字段，嵌套结构体的路径以 "." 连接。
+// 切片(如 peerSet)无法用单个值表达，不在其中
+func configKeys(val reflect.Value, prefix string) []configKey {
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val = reflect.New(val.Type().Elem())
+		}
+		val = val.Elem()
+	}
+
+	var keys []configKey
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Type().Field(i)
+		tag := field.Tag.Get("mapstructure")
+		if len(tag) == 0 || tag == "-" {
+			continue
+		}
+
+		path := prefix + tag
+		fv := val.Field(i)
+		switch {
+		case fv.Type() == durationType:
+			keys = append(keys, configKey{path, fv})
+		case fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct,
+			fv.Kind() == reflect.Struct:
+			keys = append(keys, configKeys(fv, path+".")...)
+		case fv.Kind() == reflect.Slice, fv.Kind() == reflect.Map:
+			continue
+		default:
+			keys = append(keys, configKey{path, fv})
+		}
+	}
+
+	return keys
+}
+
+// EnvName 返回配置项对应的环境变量名
+func EnvName(path string) string {
+	return EnvPrefix + "_" + strings.ToUpper(envReplacer.Replace(path))
+}
+
+// RegisterFlags 在 fs 上为 Config 的每个标量字段注册同名命令行参数(如 --netcnf.heartbeat)，
+// 默认值取自 DefaultConfig()，并将 fs 记录为 ConfigFlags
+func RegisterFlags(fs *pflag.FlagSet) {
+	for _, k := range configKeys(reflect.ValueOf(DefaultConfig()), "") {
+		usage := "overrides " + k.path + " (env " + EnvName(k.path) + ")"
+		switch v := k.value.Interface().(type) {
+		case time.Duration:
+			fs.Duration(k.path, v, usage)
+		case bool:
+			fs.Bool(k.path, v, usage)
+		case int:
+			fs.Int(k.path, v, usage)
+		case uint:
+			fs.Uint(k.path, v, usage)
+		case string:
+			fs.String(k.path, v, usage)
+		}
+	}
+
+	ConfigFlags = fs
+}
+
+// AutomaticEnv 使 Loader 读取以 prefix 为前缀的环境变量，覆盖 target 中的同名配置项
+func (l *Loader) AutomaticEnv(prefix string) {
+	l.envPrefix = prefix
+}
+
+// BindFlags 使 Loader 读取 fs 中与 target 配置项同名、且在命令行中显式设置的参数
+func (l *Loader) BindFlags(fs *pflag.FlagSet) {
+	l.flags = fs
+}
+
+func (l *Loader) bindOverrides(v *viper.Viper) error {
+	if len(l.envPrefix) == 0 && l.flags == nil {
+		return nil
+	}
+
+	if len(l.envPrefix) > 0 {
+		v.SetEnvPrefix(l.envPrefix)
+		v.SetEnvKeyReplacer(envReplacer)
+		v.AutomaticEnv()
+	}
+
+	for _, k := range configKeys(reflect.ValueOf(l.target), "") {
+		if len(l.envPrefix) > 0 {
+			if err := v.BindEnv(k.path); err != nil {
+				return err
+			}
+		}
+
+		if l.flags == nil {
+			continue
+		}
+
+		if flag := l.flags.Lookup(k.path); flag != nil && flag.Changed {
+			if err := v.BindPFlag(k.path, flag); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
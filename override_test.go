@@ -0,0 +1,63 @@
+package conf
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestEnvOverride(t *testing.T) {
+	os.Setenv("BOLAXY_NETCNF_HEARTBEAT", "250ms")
+	os.Setenv("BOLAXY_SYNC_LIMIT", "42")
+	defer os.Unsetenv("BOLAXY_NETCNF_HEARTBEAT")
+	defer os.Unsetenv("BOLAXY_SYNC_LIMIT")
+
+	cnf, err := LoadConfig(testFilePath)
+	if err != nil {
+		t.Fatalf("failed to load config file. cause: %v\n", err)
+	}
+
+	if cnf.NetCnf.Heartbeat != 250*time.Millisecond {
+		t.Errorf("expected heartbeat 250ms from env, got %v", cnf.NetCnf.Heartbeat)
+	}
+
+	if cnf.SyncLimit != 42 {
+		t.Errorf("expected sync-limit 42 from env, got %d", cnf.SyncLimit)
+	}
+
+	if cnf.NetCnf.TCPTimeout != time.Second {
+		t.Errorf("expected tcp-timeout 1s from file, got %v", cnf.NetCnf.TCPTimeout)
+	}
+}
+
+func TestFlagOverride(t *testing.T) {
+	defer func() { ConfigFlags = nil }()
+
+	os.Setenv("BOLAXY_NETCNF_HEARTBEAT", "250ms")
+	defer os.Unsetenv("BOLAXY_NETCNF_HEARTBEAT")
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	RegisterFlags(fs)
+	if err := fs.Parse([]string{"--netcnf.heartbeat=100ms", "--logcnf.rotationcount=3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cnf, err := LoadConfig(testFilePath)
+	if err != nil {
+		t.Fatalf("failed to load config file. cause: %v\n", err)
+	}
+
+	if cnf.NetCnf.Heartbeat != 100*time.Millisecond {
+		t.Errorf("expected heartbeat 100ms from flag, got %v", cnf.NetCnf.Heartbeat)
+	}
+
+	if cnf.LogCnf.RotationCount != 3 {
+		t.Errorf("expected rotationcount 3 from flag, got %d", cnf.LogCnf.RotationCount)
+	}
+
+	if cnf.NetCnf.MaxPool != 2 {
+		t.Errorf("expected max-pool 2 from file, got %d", cnf.NetCnf.MaxPool)
+	}
+}
@@ -2,7 +2,11 @@ package conf
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"strings"
 
 	"github.com/bolaxy/common"
@@ -17,9 +21,25 @@ type Peer struct {
 	HttpPort  string `mapstructure:"httpport"`
 	TcpPort   string `mapstructure:"tcpport"`
 
+	// Power is the peer's voting power. It is optional; zero counts as 1 so
+	// that unweighted peer lists keep one peer, one vote.
+	Power uint64 `mapstructure:"power" json:",omitempty"`
+
+	// Role is one of RoleValidator, RoleObserver or RoleBootstrap. An empty
+	// Role means RoleValidator.
+	Role string `mapstructure:"role" json:",omitempty"`
+
 	id uint32
 }
 
+// Peer roles. Only validators take part in consensus: observers follow the
+// network without voting and bootstrap peers are only used to join it.
+const (
+	RoleValidator = "validator"
+	RoleObserver  = "observer"
+	RoleBootstrap = "bootstrap"
+)
+
 // NewPeer is a factory method for creating a new Peer instance
 func NewPeer(pubKeyHex, netAddr, alias, httpPort, tcpPort string) *Peer {
 	peer := &Peer{
@@ -32,6 +52,12 @@ func NewPeer(pubKeyHex, netAddr, alias, httpPort, tcpPort string) *Peer {
 	return peer
 }
 
+// copy returns a copy of the peer that shares no state with it
+func (p *Peer) copy() *Peer {
+	c := *p
+	return &c
+}
+
 // ID returns an ID for the peer, calculating a hash is one is not available
 // XXX Not very nice
 func (p *Peer) ID() uint32 {
@@ -42,6 +68,19 @@ func (p *Peer) ID() uint32 {
 	return p.id
 }
 
+// VotingPower returns Power, or 1 when Power is not set
+func (p *Peer) VotingPower() uint64 {
+	if p.Power == 0 {
+		return 1
+	}
+	return p.Power
+}
+
+// IsValidator reports whether the peer takes part in consensus
+func (p *Peer) IsValidator() bool {
+	return p.Role == "" || p.Role == RoleValidator
+}
+
 // PubKeyString returns the upper-case version of PubKeyHex. It is used for
 // indexing in maps with string keys.
 // XXX do something nicer
@@ -54,6 +93,83 @@ func (p *Peer) PubKeyBytes() []byte {
 	return common.FromHex(p.PubKeyHex)
 }
 
+// PublicKey parses PubKeyHex, which may be in compressed or uncompressed
+// form, and checks that the point lies on secp256k1
+func (p *Peer) PublicKey() (*ecdsa.PublicKey, error) {
+	return parsePubKey(p.PubKeyHex)
+}
+
+// AccountAddress returns the keccak-derived account address of the peer's
+// public key. It is not to be confused with the Address field, which holds
+// the peer's network address.
+func (p *Peer) AccountAddress() (common.Address, error) {
+	pub, err := p.PublicKey()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// CompressedPubKey returns the 33-byte compressed form of the public key
+func (p *Peer) CompressedPubKey() ([]byte, error) {
+	pub, err := p.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.CompressPubkey(pub), nil
+}
+
+// NormalizePubKeyHex accepts a compressed or uncompressed public key, with or
+// without 0x prefix, and returns the canonical upper-case uncompressed form
+// used by PubKeyHex
+func NormalizePubKeyHex(pubKeyHex string) (string, error) {
+	pub, err := parsePubKey(pubKeyHex)
+	if err != nil {
+		return "", err
+	}
+
+	return PubKeyHex(pub), nil
+}
+
+func parsePubKey(pubKeyHex string) (*ecdsa.PublicKey, error) {
+	b, err := decodeHex(pubKeyHex)
+	if err != nil {
+		return nil, err
+	}
+
+	var pub *ecdsa.PublicKey
+	switch len(b) {
+	case 33:
+		pub, err = crypto.DecompressPubkey(b)
+	case 65:
+		pub, err = crypto.UnmarshalPubkey(b)
+	default:
+		return nil, fmt.Errorf("invalid pubkey length %d", len(b))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid pubkey: %v", err)
+	}
+
+	if !crypto.S256().IsOnCurve(pub.X, pub.Y) {
+		return nil, errors.New("invalid pubkey: point is not on secp256k1")
+	}
+
+	return pub, nil
+}
+
+// normalizePubKeys rewrites every valid pubkey to the canonical uncompressed
+// form. Invalid keys are left untouched for Validate to report.
+func normalizePubKeys(peers PeerList) {
+	for _, p := range peers {
+		if pub, err := NormalizePubKeyHex(p.PubKeyHex); err == nil && pub != p.PubKeyHex {
+			p.PubKeyHex = pub
+			p.id = 0
+		}
+	}
+}
+
 // Marshal marshals the json representation of the peer
 // json encoding excludes the ID field
 func (p *Peer) Marshal() ([]byte, error) {
@@ -81,12 +197,29 @@ func (p *Peer) Unmarshal(data []byte) error {
 	return nil
 }
 
+// HttpAddress joins Address and HttpPort, bracketing IPv6 addresses
 func (p *Peer) HttpAddress() string {
-	return p.Address + ":" + p.HttpPort
+	return net.JoinHostPort(p.host(), p.HttpPort)
 }
 
+// TcpAddress joins Address and TcpPort, bracketing IPv6 addresses
 func (p *Peer) TcpAddress() string {
-	return p.Address + ":" + p.TcpPort
+	return net.JoinHostPort(p.host(), p.TcpPort)
+}
+
+// HttpEndpoint returns the validated HTTP endpoint of the peer
+func (p *Peer) HttpEndpoint() (Endpoint, error) {
+	return NewEndpoint(p.Address, p.HttpPort)
+}
+
+// TcpEndpoint returns the validated TCP endpoint of the peer
+func (p *Peer) TcpEndpoint() (Endpoint, error) {
+	return NewEndpoint(p.Address, p.TcpPort)
+}
+
+// host returns Address without the brackets an IPv6 address may be written with
+func (p *Peer) host() string {
+	return strings.TrimSuffix(strings.TrimPrefix(p.Address, "["), "]")
 }
 
 // ExcludePeer is used to exclude a single peer from a list of peers.
@@ -2,14 +2,24 @@ package conf
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
+	"sort"
 	"strings"
 
 	"github.com/bolaxy/common/hexutil"
 	"github.com/bolaxy/crypto"
 )
 
+var (
+	SelfNotFound  = errors.New("self peer not found in peer list")
+	DuplicatePeer = errors.New("duplicate peer")
+	PowerOverflow = errors.New("total voting power overflows uint64")
+)
+
 // PeerSet is a set of Peers forming a consensus network
 type PeerSet struct {
 	Peers    []*Peer          `json:"peers"`
@@ -21,24 +31,35 @@ type PeerSet struct {
 	hex           string
 	superMajority *int
 	trustCount    *int
+	totalPower    *uint64
 }
 
 /* Constructors */
 
-// NewPeerSet creates a new PeerSet from a list of Peers
+// NewPeerSet creates a new PeerSet from a list of Peers. The peers are
+// copied, so neither the caller's slice nor its Peers are modified or shared
+// with the PeerSet. Cached values are computed up front so that a PeerSet
+// can be read from several goroutines without synchronisation.
 func NewPeerSet(peers PeerList) *PeerSet {
 	peerSet := &PeerSet{
+		Peers:    make([]*Peer, 0, len(peers)),
 		ByPubKey: make(map[string]*Peer),
 		ByID:     make(map[uint32]*Peer),
 	}
 
-	for _, peer := range peers {
+	for _, p := range peers {
+		peer := p.copy()
 		peer.PubKeyHex = strings.ToUpper(peer.PubKeyHex)
+		peerSet.Peers = append(peerSet.Peers, peer)
 		peerSet.ByPubKey[peer.PubKeyString()] = peer
 		peerSet.ByID[peer.ID()] = peer
 	}
 
-	peerSet.Peers = peers
+	peerSet.hash = hashPeers(peerSet.sortedPeers())
+	peerSet.hex = hexutil.Encode(peerSet.hash)
+	peerSet.SuperMajority()
+	peerSet.TrustCount()
+	peerSet.TotalPower()
 
 	return peerSet
 }
@@ -60,33 +81,95 @@ func NewPeerSetFromPeerSliceBytes(peerSliceBytes []byte) (*PeerSet, error) {
 }
 
 // WithNewPeer returns a new PeerSet with a list of peers including the new one.
+// The receiver is left unchanged.
 func (peerSet *PeerSet) WithNewPeer(peer *Peer) *PeerSet {
-	peers := peerSet.Peers
+	peers := make([]*Peer, 0, len(peerSet.Peers)+1)
+	peers = append(peers, peerSet.Peers...)
 
 	// don't add it if it already exists
-	if _, ok := peerSet.ByID[peer.ID()]; !ok {
+	if _, ok := peerSet.ByPubKey[strings.ToUpper(peer.PubKeyHex)]; !ok {
 		peers = append(peers, peer)
 	}
 
-	newPeerSet := NewPeerSet(peers)
-	return newPeerSet
+	return NewPeerSet(peers)
 }
 
 // WithRemovedPeer returns a new PeerSet with a list of peers excluding the
-// provided one
+// provided one. The receiver is left unchanged.
 func (peerSet *PeerSet) WithRemovedPeer(peer *Peer) *PeerSet {
-	var peers []*Peer
+	pubKey := strings.ToUpper(peer.PubKeyHex)
+
+	peers := make([]*Peer, 0, len(peerSet.Peers))
 	for _, p := range peerSet.Peers {
-		if p.PubKeyHex != peer.PubKeyHex {
+		if p.PubKeyHex != pubKey {
 			peers = append(peers, p)
 		}
 	}
-	newPeerSet := NewPeerSet(peers)
-	return newPeerSet
+
+	return NewPeerSet(peers)
+}
+
+// WithReplacedPeer returns a new PeerSet in which the peer with the same
+// public key as the provided one is replaced by it, keeping its position.
+// This is used to update a peer's alias or endpoints. If no such peer exists
+// the new PeerSet has the same peers as the receiver, which is left unchanged.
+func (peerSet *PeerSet) WithReplacedPeer(peer *Peer) *PeerSet {
+	pubKey := strings.ToUpper(peer.PubKeyHex)
+
+	peers := make([]*Peer, 0, len(peerSet.Peers))
+	for _, p := range peerSet.Peers {
+		if p.PubKeyHex == pubKey {
+			p = peer
+		}
+		peers = append(peers, p)
+	}
+
+	return NewPeerSet(peers)
 }
 
 /* ToSlice Methods */
 
+// Validators returns the peers that take part in consensus. Quorum
+// calculations and the hash only count these. It is computed from Peers, so
+// PeerSets decoded from JSON or written as literals work as well; a public
+// key listed twice is only counted once.
+func (peerSet *PeerSet) Validators() []*Peer {
+	var (
+		res  []*Peer
+		seen = make(map[string]bool, len(peerSet.Peers))
+	)
+
+	for _, peer := range peerSet.Peers {
+		pubKey := strings.ToUpper(peer.PubKeyHex)
+		if !peer.IsValidator() || seen[pubKey] {
+			continue
+		}
+		seen[pubKey] = true
+		res = append(res, peer)
+	}
+	return res
+}
+
+// Observers returns the non-voting observer peers
+func (peerSet *PeerSet) Observers() []*Peer {
+	return peerSet.withRole(RoleObserver)
+}
+
+// BootstrapPeers returns the peers that are only used to join the network
+func (peerSet *PeerSet) BootstrapPeers() []*Peer {
+	return peerSet.withRole(RoleBootstrap)
+}
+
+func (peerSet *PeerSet) withRole(role string) []*Peer {
+	var res []*Peer
+	for _, peer := range peerSet.Peers {
+		if peer.Role == role {
+			res = append(res, peer)
+		}
+	}
+	return res
+}
+
 // PubKeys returns the PeerSet's slice of public keys
 func (peerSet *PeerSet) PubKeys() []string {
 	res := make([]string, 0, len(peerSet.Peers))
@@ -116,22 +199,40 @@ func (peerSet *PeerSet) Len() int {
 	return len(peerSet.ByPubKey)
 }
 
-// Hash uniquely identifies a PeerSet. It is computed by sorting the peers set
-// by ID, and hashing (SHA256) their public keys together, one by one.
+// LegacyPeerSetHash makes Hash and Hex use the order-dependent LegacyHash.
+// Existing chains whose PeerSet hashes were computed before the canonical
+// hash was introduced should set it before any PeerSet is hashed.
+var LegacyPeerSetHash = false
+
+// Hash uniquely identifies a PeerSet. It is computed by sorting the
+// validators by ID, breaking ties by public key, and hashing (SHA256) their
+// public keys together, one by one. The result does not depend on the order
+// of Peers, nor on observer and bootstrap peers.
 func (peerSet *PeerSet) Hash() ([]byte, error) {
+	if LegacyPeerSetHash {
+		return peerSet.LegacyHash()
+	}
+
 	if len(peerSet.hash) == 0 {
-		var hash []byte
-		for _, p := range peerSet.Peers {
-			pk := p.PubKeyBytes()
-			hash = crypto.SimpleHashFromTwoHashes(hash, pk)
-		}
-		peerSet.hash = hash
+		peerSet.hash = hashPeers(peerSet.sortedPeers())
 	}
 	return peerSet.hash, nil
 }
 
+// LegacyHash hashes the public keys of the validators in the order of Peers,
+// as Hash did before it was made canonical. The same set listed in a different order yields a
+// different hash.
+func (peerSet *PeerSet) LegacyHash() ([]byte, error) {
+	return hashPeers(peerSet.Validators()), nil
+}
+
 // Hex is the hexadecimal representation of Hash
 func (peerSet *PeerSet) Hex() string {
+	if LegacyPeerSetHash {
+		hash, _ := peerSet.LegacyHash()
+		return hexutil.Encode(hash)
+	}
+
 	if len(peerSet.hex) == 0 {
 		hash, _ := peerSet.Hash()
 		peerSet.hex = hexutil.Encode(hash)
@@ -139,6 +240,37 @@ func (peerSet *PeerSet) Hex() string {
 	return peerSet.hex
 }
 
+func (peerSet *PeerSet) sortedPeers() []*Peer {
+	peers := peerSet.Validators()
+
+	sort.Slice(peers, func(i, j int) bool {
+		if peers[i].ID() != peers[j].ID() {
+			return peers[i].ID() < peers[j].ID()
+		}
+		return bytes.Compare(peers[i].PubKeyBytes(), peers[j].PubKeyBytes()) < 0
+	})
+
+	return peers
+}
+
+// hashPeers hashes the public keys of peers in order. The voting power of a
+// peer, when not the default of 1, is appended to its public key so that sets
+// without weights keep their previous hash and an explicit power of 1 hashes
+// the same as an unset one.
+func hashPeers(peers []*Peer) []byte {
+	var hash []byte
+	for _, p := range peers {
+		data := p.PubKeyBytes()
+		if p.VotingPower() != 1 {
+			var power [8]byte
+			binary.BigEndian.PutUint64(power[:], p.VotingPower())
+			data = append(data, power[:]...)
+		}
+		hash = crypto.SimpleHashFromTwoHashes(hash, data)
+	}
+	return hash
+}
+
 // Marshal marshals the peerset
 func (peerSet *PeerSet) Marshal() ([]byte, error) {
 	var buf bytes.Buffer
@@ -149,11 +281,11 @@ func (peerSet *PeerSet) Marshal() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// SuperMajority return the number of peers that forms a strong majortiy (+2/3)
-// in the PeerSet
+// SuperMajority return the number of validators that forms a strong majortiy
+// (+2/3) in the PeerSet
 func (peerSet *PeerSet) SuperMajority() int {
 	if peerSet.superMajority == nil {
-		val := 2*peerSet.Len()/3 + 1
+		val := 2*len(peerSet.Validators())/3 + 1
 		peerSet.superMajority = &val
 	}
 	return *peerSet.superMajority
@@ -163,16 +295,151 @@ func (peerSet *PeerSet) SuperMajority() int {
 func (peerSet *PeerSet) TrustCount() int {
 	if peerSet.trustCount == nil {
 		val := 0
-		if len(peerSet.Peers) > 1 {
-			val = int(math.Ceil(float64(peerSet.Len()) / float64(3)))
+		if n := len(peerSet.Validators()); n > 1 {
+			val = int(math.Ceil(float64(n) / float64(3)))
 		}
 		peerSet.trustCount = &val
 	}
 	return *peerSet.trustCount
 }
 
+// TotalPower returns the sum of the voting power of the validators. The sum
+// saturates at math.MaxUint64; ValidatePeerList rejects peer lists whose sum
+// overflows.
+func (peerSet *PeerSet) TotalPower() uint64 {
+	if peerSet.totalPower == nil {
+		var val uint64
+		for _, p := range peerSet.Validators() {
+			val = addPower(val, p.VotingPower())
+		}
+		peerSet.totalPower = &val
+	}
+	return *peerSet.totalPower
+}
+
+// SuperMajorityPower returns the voting power that forms a strong majority
+// (+2/3) of TotalPower. It equals SuperMajority when no peer sets Power.
+func (peerSet *PeerSet) SuperMajorityPower() uint64 {
+	total := peerSet.TotalPower()
+	// 2*total/3 without overflowing
+	return 2*(total/3) + 2*(total%3)/3 + 1
+}
+
+// TrustPower returns the weighted counterpart of TrustCount, the voting
+// power that guarantees at least one honest peer (+1/3 of TotalPower)
+func (peerSet *PeerSet) TrustPower() uint64 {
+	if len(peerSet.Validators()) <= 1 {
+		return 0
+	}
+
+	total := peerSet.TotalPower()
+	val := total / 3
+	if total%3 != 0 {
+		val++
+	}
+	return val
+}
+
+// PowerOf returns the combined voting power of the validators with the given
+// IDs. Unknown, non-validator and repeated IDs are ignored.
+func (peerSet *PeerSet) PowerOf(ids []uint32) uint64 {
+	var (
+		power uint64
+		seen  = make(map[uint32]bool, len(ids))
+	)
+
+	for _, id := range ids {
+		p, ok := peerSet.ByID[id]
+		if !ok || !p.IsValidator() || seen[id] {
+			continue
+		}
+		seen[id] = true
+		power = addPower(power, p.VotingPower())
+	}
+
+	return power
+}
+
+// addPower adds two voting powers, saturating at math.MaxUint64 so that an
+// overflowing sum cannot wrap around to a small total
+func addPower(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
+// checkTotalPower returns PowerOverflow if the voting power of the validators
+// in peers does not fit in a uint64
+func checkTotalPower(peers PeerList) error {
+	var total uint64
+	for _, p := range peers {
+		if !p.IsValidator() {
+			continue
+		}
+		if total > math.MaxUint64-p.VotingPower() {
+			return PowerOverflow
+		}
+		total += p.VotingPower()
+	}
+	return nil
+}
+
+// HasQuorum reports whether the peers with the given IDs hold at least
+// SuperMajorityPower
+func (peerSet *PeerSet) HasQuorum(ids []uint32) bool {
+	return peerSet.PowerOf(ids) >= peerSet.SuperMajorityPower()
+}
+
 func (peerSet *PeerSet) clearCache() {
 	peerSet.hash = []byte{}
 	peerSet.hex = ""
 	peerSet.superMajority = nil
+	peerSet.trustCount = nil
+	peerSet.totalPower = nil
+}
+
+// ValidatePeerList checks that self names exactly one peer in the list, that
+// no two peers share an alias, a public key or an ID, and that the total
+// voting power of the validators fits in a uint64.
+func ValidatePeerList(self string, peers PeerList) error {
+	if err := validatePeers(peers); err != nil {
+		return err
+	}
+
+	if SelfPeer(self, peers) == nil {
+		return fmt.Errorf("%w: %q", SelfNotFound, self)
+	}
+
+	return nil
+}
+
+// validatePeers performs the checks of ValidatePeerList that do not involve
+// self, for peer lists that are not part of a node's own config.
+func validatePeers(peers PeerList) error {
+	var (
+		aliases = make(map[string]struct{}, len(peers))
+		pubKeys = make(map[string]struct{}, len(peers))
+		ids     = make(map[uint32]string, len(peers))
+	)
+
+	for _, p := range peers {
+		if _, ok := aliases[p.Alias]; ok {
+			return fmt.Errorf("%w: alias %q", DuplicatePeer, p.Alias)
+		}
+		aliases[p.Alias] = struct{}{}
+
+		pubKey := strings.ToUpper(p.PubKeyHex)
+		if _, ok := pubKeys[pubKey]; ok {
+			return fmt.Errorf("%w: pubkey of %q", DuplicatePeer, p.Alias)
+		}
+		pubKeys[pubKey] = struct{}{}
+
+		if other, ok := ids[p.ID()]; ok {
+			return fmt.Errorf("%w: id %d shared by %q and %q", DuplicatePeer, p.ID(), other, p.Alias)
+		}
+		ids[p.ID()] = p.Alias
+	}
+
+	return checkTotalPower(peers)
 }
@@ -0,0 +1,384 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"testing/quick"
+
+	"github.com/bolaxy/crypto"
+)
+
+func testPeers() PeerList {
+	return PeerList{
+		NewPeer("0X04C14E88A8ECB9AC751CAEE69241BD81B01E3C7F49DE7A25B5F45389C4179B56C819CF4D2155DBE78B7A20B47CF89BFF0B8FB61FACF1B098639AE57F4AA01DFD46", "127.0.0.1", "node1", "8001", "1338"),
+		NewPeer("0X041359B1A08D55F3D1E2261A731B841B72ECF635AADD50744EE9B273F769C672D6B8CC38B4C32F00475578E5AA4D40819D749176A38A1C9484554F6E35FD6549D9", "127.0.0.1", "node2", "8002", "1339"),
+	}
+}
+
+func TestValidatePeerList(t *testing.T) {
+	peers := testPeers()
+	if err := ValidatePeerList("node1", peers); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := ValidatePeerList("node3", peers); !errors.Is(err, SelfNotFound) {
+		t.Fatalf("expected %v, got %v", SelfNotFound, err)
+	}
+
+	dupAlias := append(testPeers(), NewPeer("0X04AB", "127.0.0.1", "node1", "8003", "1340"))
+	if err := ValidatePeerList("node1", dupAlias); !errors.Is(err, DuplicatePeer) {
+		t.Fatalf("expected %v, got %v", DuplicatePeer, err)
+	}
+
+	dupKey := testPeers()
+	dupKey = append(dupKey, NewPeer(dupKey[0].PubKeyHex, "127.0.0.1", "node3", "8003", "1340"))
+	if err := ValidatePeerList("node1", dupKey); !errors.Is(err, DuplicatePeer) {
+		t.Fatalf("expected %v, got %v", DuplicatePeer, err)
+	}
+}
+
+func TestLoadConfigPeers(t *testing.T) {
+	cnf, err := TryLoadConfig(testFilePath)
+	if err != nil {
+		t.Fatalf("failed to load config file. cause: %v\n", err)
+	}
+
+	if cnf.GetPeers() == nil || cnf.GetPeers().Len() != len(cnf.Peerlist) {
+		t.Fatal("Peers was not populated from Peerlist")
+	}
+
+	self := cnf.SelfPeer()
+	if self == nil || cnf.GetPeers().ByPubKey[self.PubKeyString()] != self {
+		t.Fatal("SelfPeer is not a member of Peers")
+	}
+
+	if len(cnf.OtherPeers()) != len(cnf.Peerlist)-1 {
+		t.Fatalf("expected %d other peers, got %d", len(cnf.Peerlist)-1, len(cnf.OtherPeers()))
+	}
+
+	// 未载入配置或 peerSet 为空时不应 panic
+	if DefaultConfig().OtherPeers() != nil || OtherPeers("node0", NewPeerSet(nil)) != nil {
+		t.Fatal("expected no other peers without a peer set")
+	}
+	if len(OtherPeers("nobody", cnf.GetPeers())) != len(cnf.Peerlist) {
+		t.Fatal("all peers should be returned when the alias is not in the set")
+	}
+}
+
+// randomPeers 生成 n 个使用随机私钥的 peer
+func randomPeers(t *testing.T, n int) PeerList {
+	peers := make(PeerList, 0, n)
+	for i := 0; i < n; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		peers = append(peers, NewPeer(PubKeyHex(&key.PublicKey), "127.0.0.1", fmt.Sprintf("node%d", i), "8000", "1337"))
+	}
+
+	return peers
+}
+
+func shuffled(peers PeerList, seed int64) PeerList {
+	out := make(PeerList, len(peers))
+	for i, j := range rand.New(rand.NewSource(seed)).Perm(len(peers)) {
+		out[i] = peers[j]
+	}
+
+	return out
+}
+
+func TestPeerSetHashPermutationInvariant(t *testing.T) {
+	peers := randomPeers(t, 7)
+	want, _ := NewPeerSet(peers).Hash()
+
+	invariant := func(seed int64) bool {
+		hash, _ := NewPeerSet(shuffled(peers, seed)).Hash()
+		return bytes.Equal(hash, want)
+	}
+	if err := quick.Check(invariant, nil); err != nil {
+		t.Error(err)
+	}
+
+	// 旧的哈希依赖顺序
+	legacy, _ := NewPeerSet(peers).LegacyHash()
+	reversed := make(PeerList, 0, len(peers))
+	for i := len(peers) - 1; i >= 0; i-- {
+		reversed = append(reversed, peers[i])
+	}
+	if other, _ := NewPeerSet(reversed).LegacyHash(); bytes.Equal(legacy, other) {
+		t.Error("legacy hash should depend on peer order")
+	}
+}
+
+func TestPeerSetWithoutConstructor(t *testing.T) {
+	peers := randomPeers(t, 4)
+	peers[0].Power = 3
+	peers[3].Role = RoleObserver
+	want := NewPeerSet(peers)
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 从 JSON 解码或直接构造的 PeerSet 没有经过 NewPeerSet
+	var decoded PeerSet
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ps := range []*PeerSet{&decoded, {Peers: peers}} {
+		if ps.Hex() != want.Hex() {
+			t.Errorf("hash %s, want %s", ps.Hex(), want.Hex())
+		}
+		if ps.TotalPower() != 5 || ps.SuperMajority() != want.SuperMajority() || ps.TrustCount() != want.TrustCount() {
+			t.Errorf("got total %d, super majority %d, trust %d",
+				ps.TotalPower(), ps.SuperMajority(), ps.TrustCount())
+		}
+	}
+}
+
+func TestLegacyPeerSetHash(t *testing.T) {
+	ps := NewPeerSet(testPeers())
+	canonical := ps.Hex()
+
+	LegacyPeerSetHash = true
+	defer func() { LegacyPeerSetHash = false }()
+
+	legacy, _ := ps.LegacyHash()
+	if hash, _ := ps.Hash(); !bytes.Equal(hash, legacy) {
+		t.Error("Hash should return LegacyHash when LegacyPeerSetHash is set")
+	}
+
+	reversed := NewPeerSet(PeerList{testPeers()[1], testPeers()[0]})
+	if ps.Hex() == reversed.Hex() {
+		t.Error("legacy hex should depend on peer order")
+	}
+
+	LegacyPeerSetHash = false
+	if ps.Hex() != canonical || reversed.Hex() != canonical {
+		t.Error("canonical hex should not depend on peer order")
+	}
+}
+
+func TestPeerSetCopyOnWrite(t *testing.T) {
+	peers := testPeers()
+	peers[0].PubKeyHex = strings.ToLower(peers[0].PubKeyHex)
+	list := make(PeerList, 0, 8)
+	list = append(list, peers...)
+
+	base := NewPeerSet(list)
+	if peers[0].PubKeyHex != strings.ToLower(peers[0].PubKeyHex) {
+		t.Error("NewPeerSet should not modify the caller's peers")
+	}
+	if base.Peers[0] == peers[0] {
+		t.Error("NewPeerSet should copy the caller's peers")
+	}
+
+	extra := randomPeers(t, 2)
+	a := base.WithNewPeer(extra[0])
+	b := base.WithNewPeer(extra[1])
+	if a.Peers[2].PubKeyHex != extra[0].PubKeyHex || b.Peers[2].PubKeyHex != extra[1].PubKeyHex {
+		t.Fatal("derived peer sets share backing storage")
+	}
+	if base.Len() != 2 || len(base.Peers) != 2 {
+		t.Fatal("WithNewPeer modified the receiver")
+	}
+
+	moved := NewPeer(peers[1].PubKeyHex, "10.0.0.2", "node2", "9002", "9339")
+	replaced := base.WithReplacedPeer(moved)
+	if replaced.Peers[1].Address != "10.0.0.2" || replaced.Hex() != base.Hex() {
+		t.Errorf("replacement should update endpoints and keep the hash, got %+v", replaced.Peers[1])
+	}
+	if base.Peers[1].Address != "127.0.0.1" {
+		t.Error("WithReplacedPeer modified the receiver")
+	}
+
+	removed := base.WithRemovedPeer(peers[0])
+	if removed.Len() != 1 || base.Len() != 2 {
+		t.Errorf("WithRemovedPeer should match keys case-insensitively and leave the receiver unchanged")
+	}
+}
+
+func TestPeerSetConcurrentUse(t *testing.T) {
+	base := NewPeerSet(randomPeers(t, 4))
+	extra := randomPeers(t, 8)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = base.Hex()
+			_, _ = base.Hash()
+			_ = base.SuperMajority()
+			_ = base.TrustCount()
+			for _, id := range base.IDs() {
+				_ = base.ByID[id].ID()
+			}
+		}()
+		go func(p *Peer) {
+			defer wg.Done()
+			derived := base.WithNewPeer(p).WithReplacedPeer(p).WithRemovedPeer(base.Peers[0])
+			if derived.Len() != base.Len() {
+				t.Errorf("derived set has %d peers, want %d", derived.Len(), base.Len())
+			}
+		}(extra[i])
+	}
+	wg.Wait()
+
+	if base.Len() != 4 {
+		t.Errorf("base set has %d peers, want 4", base.Len())
+	}
+}
+
+func TestPeerSetWeightedQuorum(t *testing.T) {
+	peers := randomPeers(t, 4)
+	unweighted := NewPeerSet(peers)
+	if unweighted.TotalPower() != 4 || unweighted.SuperMajorityPower() != uint64(unweighted.SuperMajority()) ||
+		unweighted.TrustPower() != uint64(unweighted.TrustCount()) {
+		t.Fatal("unweighted power should match peer counts")
+	}
+
+	peers[0].Power = 1
+	if NewPeerSet(peers).Hex() != unweighted.Hex() {
+		t.Error("explicit power 1 should hash the same as unset power")
+	}
+
+	peers[0].Power = 10
+	peers[1].Power = 2
+	weighted := NewPeerSet(peers)
+	if weighted.Hex() == unweighted.Hex() {
+		t.Error("power should be part of the hash")
+	}
+
+	// 总权重 14，超级多数为 10，信任阈值为 5
+	if weighted.TotalPower() != 14 || weighted.SuperMajorityPower() != 10 || weighted.TrustPower() != 5 {
+		t.Fatalf("got total %d, super majority %d, trust %d",
+			weighted.TotalPower(), weighted.SuperMajorityPower(), weighted.TrustPower())
+	}
+
+	ids := weighted.IDs()
+	if !weighted.HasQuorum(ids[:1]) {
+		t.Error("peer with power 10 should reach quorum alone")
+	}
+	if weighted.HasQuorum(ids[1:]) {
+		t.Error("peers with power 4 should not reach quorum")
+	}
+	if weighted.PowerOf([]uint32{ids[1], ids[1], 42}) != 2 {
+		t.Error("repeated and unknown IDs should be ignored")
+	}
+}
+
+func TestPeerSetPowerOverflow(t *testing.T) {
+	peers := randomPeers(t, 3)
+	peers[0].Power = math.MaxUint64 - 6
+	peers[1].Power = 5
+	peers[2].Power = 1
+	if err := ValidatePeerList(peers[0].Alias, peers); err != nil {
+		t.Fatalf("total of exactly MaxUint64 should be valid: %v", err)
+	}
+
+	peers[0].Power = math.MaxUint64
+	if err := ValidatePeerList(peers[0].Alias, peers); !errors.Is(err, PowerOverflow) {
+		t.Fatalf("expected PowerOverflow, got %v", err)
+	}
+
+	// 总权重饱和于 MaxUint64，不会回绕成很小的值
+	peerSet := NewPeerSet(peers)
+	if peerSet.TotalPower() != math.MaxUint64 {
+		t.Fatalf("total power wrapped around to %d", peerSet.TotalPower())
+	}
+
+	ids := peerSet.IDs()
+	if peerSet.HasQuorum(ids[1:]) {
+		t.Error("peers with power 6 should not reach quorum")
+	}
+	if !peerSet.HasQuorum(ids) {
+		t.Error("all peers should reach quorum")
+	}
+
+	cnf, err := LoadConfig(testFilePath)
+	if err != nil {
+		t.Fatalf("failed to load config file. cause: %v\n", err)
+	}
+	cnf.Peerlist[0].Power = math.MaxUint64
+	cnf.Peerlist[1].Power = 1
+	if err := cnf.Validate(); err == nil || !strings.Contains(err.Error(), "peerSet: "+PowerOverflow.Error()) {
+		t.Fatalf("expected peerSet power overflow, got %v", err)
+	}
+}
+
+func TestLoadConfigPower(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-power")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeWatchedConfig(t, dir, `tcpport = "1337"`, "tcpport = \"1337\"\npower = 5")
+	cnf, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("failed to load config file. cause: %v\n", err)
+	}
+
+	if p := cnf.SelfPeer(); p.Power != 5 || cnf.GetPeers().TotalPower() != 7 {
+		t.Errorf("power was not loaded: %+v, total %d", p, cnf.GetPeers().TotalPower())
+	}
+
+	data, err := cnf.SelfPeer().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p Peer
+	if err := p.Unmarshal(data); err != nil || p.Power != 5 {
+		t.Errorf("power should survive marshalling, got %+v (%v)", p, err)
+	}
+}
+
+func TestPeerSetRoles(t *testing.T) {
+	peers := randomPeers(t, 5)
+	validators := NewPeerSet(peers[:3])
+
+	peers[3].Role = RoleObserver
+	peers[4].Role = RoleBootstrap
+	mixed := NewPeerSet(peers)
+
+	if len(mixed.Validators()) != 3 || len(mixed.Observers()) != 1 || len(mixed.BootstrapPeers()) != 1 {
+		t.Fatalf("got %d validators, %d observers, %d bootstrap peers",
+			len(mixed.Validators()), len(mixed.Observers()), len(mixed.BootstrapPeers()))
+	}
+
+	if mixed.Hex() != validators.Hex() {
+		t.Error("observers and bootstrap peers should not affect the hash")
+	}
+	if mixed.SuperMajority() != validators.SuperMajority() || mixed.TrustCount() != validators.TrustCount() ||
+		mixed.TotalPower() != validators.TotalPower() {
+		t.Error("observers and bootstrap peers should not count towards quorum")
+	}
+	if mixed.PowerOf([]uint32{peers[3].ID(), peers[4].ID()}) != 0 {
+		t.Error("non-validators should have no voting power")
+	}
+
+	if err := CheckConsensusAccounts(&Genesis{}, PeerList{peers[3], peers[4]}); err != nil {
+		t.Errorf("non-validators do not need consensus accounts: %v", err)
+	}
+
+	var errs ValidationErrors
+	bad := NewPeer(peers[0].PubKeyHex, "127.0.0.1", "bad", "8000", "1337")
+	bad.Role = "voter"
+	bad.validate("peerSet[0]", &errs)
+	if len(errs) != 1 || errs[0].Path != "peerSet[0].role" {
+		t.Errorf("unknown role should be reported, got %v", errs)
+	}
+}
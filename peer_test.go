@@ -0,0 +1,74 @@
+package conf
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bolaxy/common/hexutil"
+)
+
+func TestPeerPublicKey(t *testing.T) {
+	peer := testPeers()[0]
+
+	addr, err := peer.AccountAddress()
+	if err != nil {
+		t.Fatalf("failed to derive address. cause: %v\n", err)
+	}
+	if want := "0x94DD016aD0DbcA14e42e4Ec2E57710F190D48e92"; addr.Hex() != want {
+		t.Errorf("address is %s, want %s", addr.Hex(), want)
+	}
+
+	compressed, err := peer.CompressedPubKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) != 33 {
+		t.Fatalf("compressed key has %d bytes, want 33", len(compressed))
+	}
+
+	normalized, err := NormalizePubKeyHex(hexutil.Encode(compressed))
+	if err != nil {
+		t.Fatalf("failed to normalize compressed key. cause: %v\n", err)
+	}
+	if normalized != peer.PubKeyHex {
+		t.Errorf("normalized key is %s, want %s", normalized, peer.PubKeyHex)
+	}
+
+	b := peer.PubKeyBytes()
+	b[len(b)-1] ^= 1
+	for _, bad := range []string{hexutil.Encode(b), "0x04", "not hex"} {
+		if _, err := NewPeer(bad, "127.0.0.1", "bad", "8000", "1337").PublicKey(); err == nil {
+			t.Errorf("pubkey %q should be rejected", bad)
+		}
+	}
+}
+
+func TestLoadConfigNormalizesPubKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-peer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	peer := testPeers()[0]
+	compressed, _ := peer.CompressedPubKey()
+	writeWatchedConfig(t, dir, peer.PubKeyHex, hexutil.Encode(compressed))
+
+	cnf, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("config with a compressed key should load. cause: %v\n", err)
+	}
+	if p := SelfPeer("node1", cnf.Peerlist); p == nil || p.PubKeyHex != peer.PubKeyHex {
+		t.Errorf("compressed key should be normalized to %s, got %+v", peer.PubKeyHex, p)
+	}
+
+	writeWatchedConfig(t, dir, peer.PubKeyHex, strings.Replace(peer.PubKeyHex, "0X04C1", "0X04C2", 1))
+	_, err = LoadConfig(dir)
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || verrs[0].Path != "peerSet[1].pubkey" {
+		t.Errorf("invalid key should be reported at load, got %v", err)
+	}
+}
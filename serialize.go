@@ -0,0 +1,148 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	toml "github.com/pelletier/go-toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Format 为配置文件的序列化格式
+type Format string
+
+const (
+	FormatTOML Format = "toml"
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// FormatFromPath 根据文件扩展名判断序列化格式
+func FormatFromPath(path string) (Format, error) {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")); ext {
+	case "toml":
+		return FormatTOML, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported config format %q", ext)
+	}
+}
+
+// WriteTo 以 format 格式将配置写入 w，键名与载入时使用的 mapstructure 标签一致
+func (cnf *Config) WriteTo(w io.Writer, format Format) (int64, error) {
+	cnf.mu.RLock()
+	settings := toSettings(reflect.ValueOf(cnf)).(map[string]interface{})
+	cnf.mu.RUnlock()
+
+	data, err := encodeSettings(settings, format)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// SaveConfig 将配置写入 path，格式由扩展名决定
+func (cnf *Config) SaveConfig(path string) error {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if _, err := cnf.WriteTo(&buf, format); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func encodeSettings(settings map[string]interface{}, format Format) ([]byte, error) {
+	switch format {
+	case FormatTOML:
+		tree, err := toml.TreeFromMap(settings)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(tree.String()), nil
+	case FormatYAML:
+		return yaml.Marshal(settings)
+	case FormatJSON:
+		data, err := json.MarshalIndent(settings, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", format)
+	}
+}
+
+// toSettings 按 mapstructure 标签将 val 转换为 viper 可以读回的 map/slice/标量，
+// time.Duration 以字符串(如 "500ms")表示
+func toSettings(val reflect.Value) interface{} {
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+
+	if val.Type() == durationType {
+		return time.Duration(val.Int()).String()
+	}
+
+	switch val.Kind() {
+	case reflect.Struct:
+		m := make(map[string]interface{}, val.NumField())
+		for i := 0; i < val.NumField(); i++ {
+			tag := val.Type().Field(i).Tag.Get("mapstructure")
+			if len(tag) == 0 || tag == "-" {
+				continue
+			}
+
+			if v := toSettings(val.Field(i)); v != nil {
+				m[tag] = v
+			}
+		}
+		return m
+	case reflect.Map:
+		if val.Len() == 0 {
+			return nil
+		}
+		m := make(map[string]interface{}, val.Len())
+		for _, k := range val.MapKeys() {
+			m[fmt.Sprint(k.Interface())] = toSettings(val.MapIndex(k))
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if val.Kind() == reflect.Slice && val.Len() == 0 {
+			return nil
+		}
+		if val.Type().Elem().Kind() == reflect.String {
+			s := make([]string, val.Len())
+			for i := range s {
+				s[i] = val.Index(i).String()
+			}
+			return s
+		}
+		s := make([]interface{}, val.Len())
+		for i := range s {
+			s[i] = toSettings(val.Index(i))
+		}
+		return s
+	default:
+		return val.Interface()
+	}
+}
@@ -0,0 +1,50 @@
+package conf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigRoundTrip(t *testing.T) {
+	original, err := LoadConfig(testFilePath)
+	if err != nil {
+		t.Fatalf("failed to load config file. cause: %v\n", err)
+	}
+	original.DataCnf.DataDir, _ = filepath.Abs(testFilePath)
+
+	for _, ext := range []string{"toml", "yaml", "json"} {
+		dir, err := ioutil.TempDir("", "bolaxy-save")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(dir, "config."+ext)
+		if err := original.SaveConfig(path); err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("%s: failed to save config. cause: %v\n", ext, err)
+		}
+
+		loaded, err := LoadConfig(dir)
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("%s: failed to reload config. cause: %v\n", ext, err)
+		}
+
+		if changes := DiffConfig(original, loaded); len(changes) > 0 {
+			t.Errorf("%s: round trip is lossy: %v", ext, changes)
+		}
+
+		format, _ := FormatFromPath(path)
+		var first, second bytes.Buffer
+		original.WriteTo(&first, format)
+		loaded.WriteTo(&second, format)
+		if !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Errorf("%s: output is not canonical:\n%s\n%s", ext, first.String(), second.String())
+		}
+
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,256 @@
+package conf
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bolaxy/common/hexutil"
+	"github.com/bolaxy/crypto"
+)
+
+var (
+	InvalidPeerSignature = errors.New("invalid peer record signature")
+	StalePeerRecord      = errors.New("stale peer record")
+)
+
+// PeerRecord 为 peer 用自己的私钥签名的内容。Seq 由 peer 单调递增，用于识别过期的记录。
+// 记录中没有 Power 和 Role，peer 不能自行决定自己的投票权重和角色
+type PeerRecord struct {
+	Seq       uint64            `json:"seq"`
+	Alias     string            `json:"alias"`
+	PubKeyHex string            `json:"pubkey"`
+	Address   string            `json:"address"`
+	HttpPort  string            `json:"httpport"`
+	TcpPort   string            `json:"tcpport"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// SignedPeer 为带签名的 PeerRecord。从 JSON 解码时会校验签名，签名无效时解码失败
+type SignedPeer struct {
+	Record    PeerRecord `json:"record"`
+	Signature string     `json:"signature"`
+}
+
+// SignPeer 使用 p 自己的私钥 key 签名 p 的当前信息，key 必须与 p.PubKeyHex 对应
+func SignPeer(p *Peer, seq uint64, metadata map[string]string, key *ecdsa.PrivateKey) (*SignedPeer, error) {
+	if PubKeyHex(&key.PublicKey) != strings.ToUpper(p.PubKeyHex) {
+		return nil, fmt.Errorf("key does not match pubkey of %q", p.Alias)
+	}
+
+	sp := &SignedPeer{
+		Record: PeerRecord{
+			Seq:       seq,
+			Alias:     p.Alias,
+			PubKeyHex: strings.ToUpper(p.PubKeyHex),
+			Address:   p.Address,
+			HttpPort:  p.HttpPort,
+			TcpPort:   p.TcpPort,
+			Metadata:  metadata,
+		},
+	}
+
+	hash, err := sp.Record.hash()
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		return nil, err
+	}
+	sp.Signature = hexutil.Encode(sig)
+
+	return sp, nil
+}
+
+// hash 为记录的 JSON 编码的 keccak256 哈希，encoding/json 对 map 的键排序，编码是确定的
+func (r *PeerRecord) hash() ([]byte, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.Keccak256(data), nil
+}
+
+// pubKey 返回规范化的公钥，用于按 peer 索引记录
+func (r *PeerRecord) pubKey() string {
+	if pub, err := NormalizePubKeyHex(r.PubKeyHex); err == nil {
+		return pub
+	}
+	return strings.ToUpper(r.PubKeyHex)
+}
+
+// Verify 检查签名由 Record.PubKeyHex 对应的私钥作出，且记录中的端点有效
+func (sp *SignedPeer) Verify() error {
+	hash, err := sp.Record.hash()
+	if err != nil {
+		return err
+	}
+
+	sig, err := decodeHex(sp.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", InvalidPeerSignature, err)
+	}
+
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return fmt.Errorf("%w: %v", InvalidPeerSignature, err)
+	}
+
+	want, err := NormalizePubKeyHex(sp.Record.PubKeyHex)
+	if err != nil {
+		return fmt.Errorf("%w: %v", InvalidPeerSignature, err)
+	}
+
+	if PubKeyHex(pub) != want {
+		return fmt.Errorf("%w: record of %q is not signed by its own key", InvalidPeerSignature, sp.Record.Alias)
+	}
+
+	if _, err := NewEndpoint(sp.Record.Address, sp.Record.HttpPort); err != nil {
+		return fmt.Errorf("record of %q: %v", sp.Record.Alias, err)
+	}
+
+	if _, err := NewEndpoint(sp.Record.Address, sp.Record.TcpPort); err != nil {
+		return fmt.Errorf("record of %q: %v", sp.Record.Alias, err)
+	}
+
+	return nil
+}
+
+// Peer 返回记录描述的 Peer，其 Power 和 Role 为默认值
+func (sp *SignedPeer) Peer() *Peer {
+	return NewPeer(sp.Record.pubKey(), sp.Record.Address, sp.Record.Alias, sp.Record.HttpPort, sp.Record.TcpPort)
+}
+
+// UnmarshalJSON 解码并校验签名
+func (sp *SignedPeer) UnmarshalJSON(data []byte) error {
+	type signedPeer SignedPeer
+
+	var raw signedPeer
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	decoded := SignedPeer(raw)
+	if err := decoded.Verify(); err != nil {
+		return err
+	}
+
+	*sp = decoded
+	return nil
+}
+
+// PeerRecords 记录每个 peer 已接受的最大 Seq 及其记录的哈希，拒绝过期或重放的记录，可以并发使用
+type PeerRecords struct {
+	mu       sync.Mutex
+	accepted map[string]acceptedRecord
+}
+
+type acceptedRecord struct {
+	seq  uint64
+	hash []byte
+}
+
+func NewPeerRecords() *PeerRecords {
+	return &PeerRecords{accepted: make(map[string]acceptedRecord)}
+}
+
+// Accept 校验 sp。Seq 小于该 peer 已接受的 Seq，或 Seq 相同但内容不同时，返回包装 StalePeerRecord 的错误；
+// 与已接受的记录内容相同时视为未变化，直接接受
+func (r *PeerRecords) Accept(sp *SignedPeer) error {
+	if err := sp.Verify(); err != nil {
+		return err
+	}
+
+	return r.acceptAll([]*SignedPeer{sp})
+}
+
+// acceptAll 在所有记录都不过期时才一并接受，否则不修改已记录的内容
+func (r *PeerRecords) acceptAll(signed []*SignedPeer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hashes := make([][]byte, len(signed))
+	for i, sp := range signed {
+		hash, err := sp.Record.hash()
+		if err != nil {
+			return err
+		}
+		hashes[i] = hash
+
+		last, ok := r.accepted[sp.Record.pubKey()]
+		switch {
+		case !ok, sp.Record.Seq > last.seq:
+		case sp.Record.Seq < last.seq:
+			return fmt.Errorf("%w: %q has seq %d, already accepted %d", StalePeerRecord, sp.Record.Alias, sp.Record.Seq, last.seq)
+		case !bytes.Equal(hash, last.hash):
+			return fmt.Errorf("%w: %q changed its record without increasing seq %d", StalePeerRecord, sp.Record.Alias, sp.Record.Seq)
+		}
+	}
+
+	for i, sp := range signed {
+		r.accepted[sp.Record.pubKey()] = acceptedRecord{seq: sp.Record.Seq, hash: hashes[i]}
+	}
+
+	return nil
+}
+
+// NewPeerSetFromSignedPeers 由校验通过的记录创建 PeerSet。同一 peer 有多条记录时只保留 Seq 最大的一条。
+// records 为 nil 时不检查过期记录，否则被采用的记录必须全部被 records 接受，有一条过期时都不接受。
+// 没有变化的 peer 可以沿用已接受的记录。peer 的别名或公钥重复时返回包装 DuplicatePeer 的错误
+func NewPeerSetFromSignedPeers(signed []*SignedPeer, records *PeerRecords) (*PeerSet, error) {
+	var (
+		latest = make(map[string]*SignedPeer, len(signed))
+		order  []string
+	)
+
+	for _, sp := range signed {
+		if err := sp.Verify(); err != nil {
+			return nil, err
+		}
+
+		key := sp.Record.pubKey()
+		prev, ok := latest[key]
+		if !ok {
+			order = append(order, key)
+		}
+		if !ok || sp.Record.Seq > prev.Record.Seq {
+			latest[key] = sp
+		}
+	}
+
+	accepted := make([]*SignedPeer, 0, len(order))
+	peers := make(PeerList, 0, len(order))
+	for _, key := range order {
+		accepted = append(accepted, latest[key])
+		peers = append(peers, latest[key].Peer())
+	}
+
+	if err := validatePeers(peers); err != nil {
+		return nil, err
+	}
+
+	if records != nil {
+		if err := records.acceptAll(accepted); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewPeerSet(peers), nil
+}
+
+// NewPeerSetFromSignedPeerBytes 由 JSON 编码的 SignedPeer 列表创建 PeerSet，解码时校验每条记录的签名
+func NewPeerSetFromSignedPeerBytes(data []byte, records *PeerRecords) (*PeerSet, error) {
+	var signed []*SignedPeer
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, err
+	}
+
+	return NewPeerSetFromSignedPeers(signed, records)
+}
@@ -0,0 +1,154 @@
+package conf
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/bolaxy/crypto"
+)
+
+func TestSignedPeers(t *testing.T) {
+	var (
+		signed []*SignedPeer
+		peers  PeerList
+		keys   []*ecdsa.PrivateKey
+	)
+
+	for i := 0; i < 3; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := NewPeer(PubKeyHex(&key.PublicKey), "10.0.0.1", fmt.Sprintf("node%d", i), "8000", "1337")
+		sp, err := SignPeer(p, 1, map[string]string{"version": "1.0"}, key)
+		if err != nil {
+			t.Fatalf("failed to sign peer. cause: %v\n", err)
+		}
+
+		peers = append(peers, p)
+		signed = append(signed, sp)
+		keys = append(keys, key)
+	}
+
+	data, err := json.Marshal(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := NewPeerRecords()
+	ps, err := NewPeerSetFromSignedPeerBytes(data, records)
+	if err != nil {
+		t.Fatalf("failed to decode signed peers. cause: %v\n", err)
+	}
+	if ps.Hex() != NewPeerSet(peers).Hex() || ps.Peers[0].Address != "10.0.0.1" {
+		t.Errorf("decoded peer set does not match the signed peers")
+	}
+
+	// 重复接受内容相同的记录不视为过期
+	if _, err := NewPeerSetFromSignedPeerBytes(data, records); err != nil {
+		t.Errorf("unchanged records should be accepted, got %v", err)
+	}
+
+	// 只更新其中一个 peer，其余 peer 沿用已接受的记录
+	peers[0].Address = "10.0.0.2"
+	updated, err := SignPeer(peers[0], 2, nil, keys[0])
+	if err != nil {
+		t.Fatalf("failed to sign peer. cause: %v\n", err)
+	}
+	ps, err = NewPeerSetFromSignedPeers([]*SignedPeer{updated, signed[1], signed[2]}, records)
+	if err != nil {
+		t.Fatalf("updating one peer should be accepted. cause: %v\n", err)
+	}
+	if ps.Peers[0].Address != "10.0.0.2" {
+		t.Errorf("expected the updated record, got %+v", ps.Peers[0])
+	}
+
+	// Seq 不变但内容改变的记录是过期的
+	peers[1].Address = "10.0.0.3"
+	changed, err := SignPeer(peers[1], 1, nil, keys[1])
+	if err != nil {
+		t.Fatalf("failed to sign peer. cause: %v\n", err)
+	}
+	if _, err := NewPeerSetFromSignedPeers([]*SignedPeer{updated, changed, signed[2]}, records); !errors.Is(err, StalePeerRecord) {
+		t.Errorf("changed record with the same seq should be stale, got %v", err)
+	}
+	if _, err := NewPeerSetFromSignedPeerBytes(data, records); !errors.Is(err, StalePeerRecord) {
+		t.Errorf("replaying the older set should be stale, got %v", err)
+	}
+
+	tampered := []byte(strings.Replace(string(data), "10.0.0.1", "10.6.6.6", 1))
+	if _, err := NewPeerSetFromSignedPeerBytes(tampered, nil); !errors.Is(err, InvalidPeerSignature) {
+		t.Errorf("tampered record should fail to decode, got %v", err)
+	}
+
+	// peer 不能通过签名的记录设置自己的投票权重和角色
+	peers[2].Power = math.MaxUint64
+	peers[2].Role = RoleObserver
+	selfWeighted, err := SignPeer(peers[2], 2, nil, keys[2])
+	if err != nil {
+		t.Fatalf("failed to sign peer. cause: %v\n", err)
+	}
+	ps, err = NewPeerSetFromSignedPeers([]*SignedPeer{updated, signed[1], selfWeighted}, NewPeerRecords())
+	if err != nil {
+		t.Fatalf("failed to build peer set. cause: %v\n", err)
+	}
+	if ps.TotalPower() != 3 || len(ps.Validators()) != 3 || ps.HasQuorum([]uint32{ps.Peers[2].ID()}) {
+		t.Errorf("signed record should not carry power or role: total %d", ps.TotalPower())
+	}
+
+	other, _ := crypto.GenerateKey()
+	if _, err := SignPeer(peers[0], 2, nil, other); err == nil {
+		t.Error("signing with another peer's key should fail")
+	}
+}
+
+func TestPeerRecordsSequence(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPeer(PubKeyHex(&key.PublicKey), "10.0.0.1", "node0", "8000", "1337")
+
+	v1, _ := SignPeer(p, 1, nil, key)
+	p.Address = "10.0.0.2"
+	v2, _ := SignPeer(p, 2, nil, key)
+
+	// 同一 peer 的多条记录只采用 Seq 最大的一条
+	ps, err := NewPeerSetFromSignedPeers([]*SignedPeer{v2, v1}, nil)
+	if err != nil || ps.Len() != 1 || ps.Peers[0].Address != "10.0.0.2" {
+		t.Fatalf("expected the newest record, got %+v (%v)", ps, err)
+	}
+
+	records := NewPeerRecords()
+	if err := records.Accept(v2); err != nil {
+		t.Fatal(err)
+	}
+	if err := records.Accept(v1); !errors.Is(err, StalePeerRecord) {
+		t.Errorf("older record should be stale, got %v", err)
+	}
+
+	// 别名重复的记录集不被接受，也不修改已接受的 Seq
+	otherKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dup, _ := SignPeer(NewPeer(PubKeyHex(&otherKey.PublicKey), "10.0.0.9", "node0", "8000", "1337"), 1, nil, otherKey)
+	fresh := NewPeerRecords()
+	if _, err := NewPeerSetFromSignedPeers([]*SignedPeer{v2, dup}, fresh); !errors.Is(err, DuplicatePeer) {
+		t.Errorf("expected DuplicatePeer, got %v", err)
+	}
+	if err := fresh.Accept(v1); err != nil {
+		t.Errorf("rejected set should not be recorded, got %v", err)
+	}
+
+	v1.Record.Address = "10.0.0.3"
+	if err := v1.Verify(); !errors.Is(err, InvalidPeerSignature) {
+		t.Errorf("modified record should fail verification, got %v", err)
+	}
+}
@@ -0,0 +1,365 @@
+package conf
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bolaxy/common"
+	"github.com/bolaxy/common/math"
+)
+
+// ValidateOnLoad 控制 TryLoadConfig/LoadConfig 和 TryLoadGenesis 是否在载入后自动调用 Validate
+var ValidateOnLoad = true
+
+// FieldError 描述单个配置项的错误，Path 为 mapstructure 路径，如 netcnf.tcp-timeout
+type FieldError struct {
+	Path string
+	Msg  string
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Msg
+}
+
+// ValidationErrors 汇总一次校验中发现的所有错误
+type ValidationErrors []*FieldError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+
+	return fmt.Sprintf("invalid config: %s", strings.Join(msgs, "; "))
+}
+
+func (errs *ValidationErrors) add(path, format string, args ...interface{}) {
+	*errs = append(*errs, &FieldError{Path: path, Msg: fmt.Sprintf(format, args...)})
+}
+
+// err 在没有错误时返回 nil，避免返回包含 nil 切片的非 nil error
+func (errs ValidationErrors) err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// Validate 检查配置的每一项，返回包含所有问题的 ValidationErrors
+func (cnf *Config) Validate() error {
+	var errs ValidationErrors
+
+	if cnf.CacheSize <= 0 {
+		errs.add("cache-size", "must be > 0")
+	}
+
+	if cnf.SyncLimit <= 0 {
+		errs.add("sync-limit", "must be > 0")
+	}
+
+	if cnf.NetCnf == nil {
+		errs.add("netcnf", "must be set")
+	} else {
+		cnf.NetCnf.validate(&errs)
+	}
+
+	if cnf.DataCnf == nil {
+		errs.add("datacnf", "must be set")
+	} else {
+		cnf.DataCnf.validate(&errs)
+	}
+
+	if cnf.LogCnf == nil {
+		errs.add("logcnf", "must be set")
+	} else {
+		cnf.LogCnf.validate(&errs)
+	}
+
+	for i, p := range cnf.Peerlist {
+		p.validate(fmt.Sprintf("peerSet[%d]", i), &errs)
+	}
+
+	if err := checkTotalPower(cnf.Peerlist); err != nil {
+		errs.add("peerSet", "%v", err)
+	}
+
+	if len(cnf.Self) == 0 {
+		errs.add("self", "must not be empty")
+	} else if SelfPeer(cnf.Self, cnf.Peerlist) == nil {
+		errs.add("self", "%q not found in peerSet", cnf.Self)
+	}
+
+	return errs.err()
+}
+
+func (c *NetConfig) validate(errs *ValidationErrors) {
+	if c.Heartbeat <= 0 {
+		errs.add("netcnf.heartbeat", "must be > 0")
+	}
+
+	if c.TCPTimeout <= 0 {
+		errs.add("netcnf.tcp-timeout", "must be > 0")
+	}
+
+	if c.JoinTimeout < 0 {
+		errs.add("netcnf.join_timeout", "must be >= 0")
+	}
+
+	if c.MaxPool <= 0 {
+		errs.add("netcnf.max-pool", "must be > 0")
+	}
+
+	if err := validateListenAddr(c.EthAPIAddr); err != nil {
+		errs.add("netcnf.listen", "%v", err)
+	}
+}
+
+func (c *DataConfig) validate(errs *ValidationErrors) {
+	if len(c.DataDir) == 0 {
+		errs.add("datacnf.datadir", "must not be empty")
+	}
+
+	if len(c.Genesis) == 0 {
+		errs.add("datacnf.genesis", "must not be empty")
+	}
+
+	if len(c.Keystore) == 0 {
+		errs.add("datacnf.keystore", "must not be empty")
+	}
+
+	if len(c.PwdFile) == 0 {
+		errs.add("datacnf.pwd", "must not be empty")
+	}
+
+	if len(c.DbFile) == 0 {
+		errs.add("datacnf.db", "must not be empty")
+	}
+}
+
+func (c *LogConfig) validate(errs *ValidationErrors) {
+	if len(c.LogName) == 0 {
+		errs.add("logcnf.logname", "must not be empty")
+	}
+}
+
+func (p *Peer) validate(path string, errs *ValidationErrors) {
+	if len(p.Alias) == 0 {
+		errs.add(path+".alias", "must not be empty")
+	}
+
+	if len(p.PubKeyHex) == 0 {
+		errs.add(path+".pubkey", "must not be empty")
+	} else if _, err := p.PublicKey(); err != nil {
+		errs.add(path+".pubkey", "%v", err)
+	}
+
+	if len(p.Address) == 0 {
+		errs.add(path+".address", "must not be empty")
+	} else if err := validateHost(p.host()); err != nil {
+		errs.add(path+".address", "%v", err)
+	}
+
+	if err := validatePort(p.HttpPort); err != nil {
+		errs.add(path+".httpport", "%v", err)
+	}
+
+	if err := validatePort(p.TcpPort); err != nil {
+		errs.add(path+".tcpport", "%v", err)
+	}
+
+	switch p.Role {
+	case "", RoleValidator, RoleObserver, RoleBootstrap:
+	default:
+		errs.add(path+".role", "unknown role %q", p.Role)
+	}
+}
+
+// validateListenAddr 检查监听地址。host 可以为空，表示监听所有地址；端口可以为 0，由系统分配
+func validateListenAddr(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("malformed address %q", addr)
+	}
+
+	if len(host) > 0 {
+		if err := validateHost(host); err != nil {
+			return err
+		}
+	}
+
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("invalid port %q", port)
+	}
+
+	return nil
+}
+
+func validatePort(port string) error {
+	_, err := parsePort(port)
+	return err
+}
+
+// Validate 检查创世配置中的地址、余额、合约代码和存储，返回包含所有问题的 ValidationErrors，
+// alloc 中的问题以 alloc[i] 标明下标
+func (g *Genesis) Validate() error {
+	var errs ValidationErrors
+
+	if g.Version > GenesisV2 {
+		errs.add("version", "unsupported genesis version %d", g.Version)
+	}
+
+	if len(g.ChainID) == 0 {
+		errs.add("chain-id", "must not be empty")
+	}
+
+	if len(g.CoinBase) > 0 {
+		validateAddress("coinbase", g.CoinBase, &errs)
+	}
+
+	consensus := make(map[common.Address]int, len(g.ConsensusAccounts))
+	for i, account := range g.ConsensusAccounts {
+		path := fmt.Sprintf("consensus-accounts[%d]", i)
+		if !validateAddress(path, account, &errs) {
+			continue
+		}
+
+		addr := common.HexToAddress(account)
+		if j, ok := consensus[addr]; ok {
+			errs.add(path, "duplicates consensus-accounts[%d]", j)
+			continue
+		}
+		consensus[addr] = i
+	}
+
+	accounts := make(map[common.Address]int, len(g.Alloc))
+	for i, a := range g.Alloc {
+		path := fmt.Sprintf("alloc[%d]", i)
+		if validateAddress(path+".account", a.Account, &errs) {
+			addr := common.HexToAddress(a.Account)
+			if j, ok := accounts[addr]; ok {
+				errs.add(path+".account", "duplicates alloc[%d]", j)
+			} else {
+				accounts[addr] = i
+			}
+		}
+
+		validateBalance(path+".balance", a.Balance, &errs)
+		validateCode(path+".code", a.Code, &errs)
+		validateStorage(path+".storage", a.Storage, &errs)
+	}
+
+	if g.Poa != nil {
+		g.Poa.validate("poa", &errs)
+	}
+
+	if g.Launcher != nil {
+		g.Launcher.validate("launcher", &errs)
+	}
+
+	return errs.err()
+}
+
+func (p *PoaMap) validate(path string, errs *ValidationErrors) {
+	validateAddress(path+".address", p.Address, errs)
+	validateBalance(path+".balance", p.Balance, errs)
+	validateCode(path+".code", p.Code, errs)
+	validateStorage(path+".storage", p.Storage, errs)
+
+	if len(p.Abi) > 0 && !json.Valid([]byte(p.Abi)) {
+		errs.add(path+".abi", "must be valid JSON")
+	}
+
+	if len(p.SubAbi) > 0 && !json.Valid([]byte(p.SubAbi)) {
+		errs.add(path+".subabi", "must be valid JSON")
+	}
+}
+
+// validateAddress 检查 40 位十六进制地址，大小写混合时按 EIP-55 校验
+func validateAddress(path, addr string, errs *ValidationErrors) bool {
+	if !common.IsHexAddress(addr) {
+		errs.add(path, "invalid address %q", addr)
+		return false
+	}
+
+	raw := strings.TrimPrefix(strings.TrimPrefix(addr, "0x"), "0X")
+	if raw != strings.ToLower(raw) && raw != strings.ToUpper(raw) {
+		if want := common.HexToAddress(addr).Hex(); want[2:] != raw {
+			errs.add(path, "bad checksum for %q, expected %s", addr, want)
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseBalance 解析十进制或 0x 开头的十六进制余额，空字符串视为 0
+func ParseBalance(s string) (*big.Int, error) {
+	if len(s) == 0 {
+		return new(big.Int), nil
+	}
+
+	b, ok := math.ParseBig256(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid balance %q", s)
+	}
+
+	if b.Sign() < 0 {
+		return nil, fmt.Errorf("negative balance %q", s)
+	}
+
+	return b, nil
+}
+
+func validateBalance(path, balance string, errs *ValidationErrors) {
+	if _, err := ParseBalance(balance); err != nil {
+		errs.add(path, "%v", err)
+	}
+}
+
+func validateCode(path, code string, errs *ValidationErrors) {
+	if len(code) > 0 {
+		if _, err := decodeHex(code); err != nil {
+			errs.add(path, "%v", err)
+		}
+	}
+}
+
+func validateStorage(path string, storage map[string]string, errs *ValidationErrors) {
+	keys := make([]string, 0, len(storage))
+	for k := range storage {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !isHashHex(k) {
+			errs.add(path, "key %q must be a 32-byte hex value", k)
+		}
+
+		if v := storage[k]; !isHashHex(v) {
+			errs.add(path, "value %q of key %q must be a 32-byte hex value", v, k)
+		}
+	}
+}
+
+// decodeHex 解码可选 0x 前缀的十六进制字符串
+func decodeHex(s string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex %q", s)
+	}
+
+	return b, nil
+}
+
+func isHashHex(s string) bool {
+	b, err := decodeHex(s)
+	return err == nil && len(b) == common.HashLength
+}
@@ -0,0 +1,115 @@
+package conf
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+	cnf, err := LoadConfig(testFilePath)
+	if err != nil {
+		t.Fatalf("failed to load config file. cause: %v\n", err)
+	}
+
+	if err := cnf.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cnf.NetCnf.Heartbeat = 0
+	cnf.NetCnf.TCPTimeout = 0
+	cnf.NetCnf.MaxPool = -1
+	cnf.NetCnf.EthAPIAddr = "localhost"
+	cnf.DataCnf.DataDir = ""
+	cnf.CacheSize = 0
+	cnf.Self = "nobody"
+
+	err = cnf.Validate()
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+
+	want := []string{
+		"cache-size: must be > 0",
+		"netcnf.heartbeat: must be > 0",
+		"netcnf.tcp-timeout: must be > 0",
+		"netcnf.max-pool: must be > 0",
+		`netcnf.listen: malformed address "localhost"`,
+		"datacnf.datadir: must not be empty",
+		`self: "nobody" not found in peerSet`,
+	}
+
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), err)
+	}
+
+	for i, e := range errs {
+		if e.Error() != want[i] {
+			t.Errorf("expected %q, got %q", want[i], e.Error())
+		}
+	}
+}
+
+func TestValidateListenAddr(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:8080", ":8080", "localhost:0", "[::1]:0", "node-1.example.com:8080"} {
+		if err := validateListenAddr(addr); err != nil {
+			t.Errorf("%s: unexpected error: %v", addr, err)
+		}
+	}
+
+	for _, addr := range []string{"localhost", "300.1.1.1:8080", "bad_host:8080", "localhost:65536", "localhost:-1"} {
+		if err := validateListenAddr(addr); err == nil {
+			t.Errorf("%s: expected error", addr)
+		}
+	}
+}
+
+func TestGenesisValidate(t *testing.T) {
+	for _, name := range genesisNames {
+		genesis, err := TryLoadGenesis(testFilePath, name)
+		if err != nil {
+			t.Fatalf("failed to load genesis file. cause: %v\n", err)
+		}
+
+		if err := genesis.Validate(); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+
+	genesis := &Genesis{
+		ChainID:  "1",
+		CoinBase: "0x51baab5243db87cbed2bebebad825255e5061F4D",
+		Alloc: []Alloc{
+			{Account: "0x51BaAb5243DB87CbEd2beBebAD825255E5061f4D", Balance: "1e18"},
+			{Account: "0x51baab5243db87cbed2bebebad825255e5061f4d", Balance: "0x10", Code: "0x6z"},
+			{Account: "0x1234", Balance: "-1", Storage: map[string]string{"0x01": "0x02"}},
+		},
+	}
+
+	err := genesis.Validate()
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+
+	want := []string{
+		`coinbase: bad checksum for "0x51baab5243db87cbed2bebebad825255e5061F4D", expected 0x51BaAb5243DB87CbEd2beBebAD825255E5061f4D`,
+		`alloc[0].balance: invalid balance "1e18"`,
+		"alloc[1].account: duplicates alloc[0]",
+		`alloc[1].code: invalid hex "0x6z"`,
+		`alloc[2].account: invalid address "0x1234"`,
+		`alloc[2].balance: negative balance "-1"`,
+		`alloc[2].storage: key "0x01" must be a 32-byte hex value`,
+		`alloc[2].storage: value "0x02" of key "0x01" must be a 32-byte hex value`,
+	}
+
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), err)
+	}
+
+	for i, e := range errs {
+		if e.Error() != want[i] {
+			t.Errorf("expected %q, got %q", want[i], e.Error())
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultWatchInterval 为 Watcher 检查配置文件是否变化的默认间隔
+const DefaultWatchInterval = time.Second
+
+// unsafeFields 中的配置项无法在运行时修改，以 "." 结尾的表示整个配置段。
+// GetLogger 只构造一次日志文件的切分 hook，logcnf 中的配置项都不能热更新
+var unsafeFields = []string{
+	"self",
+	"datacnf.",
+	"netcnf.listen",
+	"logcnf.",
+	"peerSet",
+}
+
+// Change 描述一次热更新中某个配置项的变化，Path 为 mapstructure 路径
+type Change struct {
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.Path, c.Old, c.New)
+}
+
+// UnsafeChangeError 表示配置文件修改了不能热更新的配置项，本次更新被拒绝
+type UnsafeChangeError struct {
+	Changes []Change
+}
+
+func (e *UnsafeChangeError) Error() string {
+	paths := make([]string, 0, len(e.Changes))
+	for _, c := range e.Changes {
+		paths = append(paths, c.Path)
+	}
+
+	return fmt.Sprintf("refusing to hot reload, restart required for: %s", strings.Join(paths, ", "))
+}
+
+// Watcher 监视配置文件的变化，重新载入并校验后原子地替换当前配置，
+// 并将变化的配置项通知给订阅者
+type Watcher struct {
+	file     string
+	interval time.Duration
+	current  atomic.Value // *Config
+	reload   sync.Mutex
+
+	mu      sync.Mutex
+	subs    []func(*Config, []Change)
+	onError func(error)
+	modTime time.Time
+	size    int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Watch 按 LoadConfig 的规则载入配置文件，并返回监视该文件的 Watcher。
+// 调用 Start 开始轮询，或直接调用 Reload 手动触发更新
+func Watch(filePath string, cfgName ...string) (*Watcher, error) {
+	cname := configName
+	if len(cfgName) == 1 {
+		cname = cfgName[0]
+	}
+
+	cnf := DefaultConfig()
+	l := configLoader(newSearchLoader(cname, cnf, filePath))
+	if err := loadConfig(l, cnf); err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		file:     l.Source(),
+		interval: DefaultWatchInterval,
+	}
+	w.current.Store(cnf)
+	w.modTime, w.size = w.stat()
+
+	return w, nil
+}
+
+// Config 返回当前生效的配置
+func (w *Watcher) Config() *Config {
+	return w.current.Load().(*Config)
+}
+
+// File 返回被监视的配置文件
+func (w *Watcher) File() string {
+	return w.file
+}
+
+// Subscribe 注册订阅者，每次成功热更新后以新配置和变化列表调用 fn
+func (w *Watcher) Subscribe(fn func(cnf *Config, changes []Change)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subs = append(w.subs, fn)
+}
+
+// OnError 设置轮询过程中重新载入失败时的回调。未设置时错误写入 Logger
+func (w *Watcher) OnError(fn func(error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onError = fn
+}
+
+// SetInterval 设置轮询间隔，需在 Start 之前调用
+func (w *Watcher) SetInterval(interval time.Duration) {
+	w.interval = interval
+}
+
+// Start 在后台轮询配置文件，文件的修改时间或大小变化时重新载入
+func (w *Watcher) Start() {
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				if !w.modified() {
+					continue
+				}
+
+				if _, err := w.Reload(); err != nil {
+					w.reportError(err)
+				}
+			}
+		}
+	}()
+}
+
+// Close 停止后台轮询
+func (w *Watcher) Close() {
+	if w.stop == nil {
+		return
+	}
+
+	close(w.stop)
+	<-w.done
+	w.stop = nil
+}
+
+// Reload 重新载入并校验配置文件。配置没有变化时返回空列表；修改了 unsafeFields 中的
+// 配置项时返回 *UnsafeChangeError，当前配置保持不变。
+// Global 由 TryLoadConfig 从同一文件载入时，新配置同时写入 Global 并更新日志级别；
+// 否则不修改 Global、Logger 等进程级状态
+func (w *Watcher) Reload() ([]Change, error) {
+	w.reload.Lock()
+	defer w.reload.Unlock()
+
+	old := w.Config()
+	cnf := DefaultConfig()
+	if err := configLoader(NewFileLoader(w.file, cnf)).Load(); err != nil {
+		return nil, err
+	}
+	normalizePubKeys(cnf.Peerlist)
+
+	if err := cnf.Validate(); err != nil {
+		return nil, err
+	}
+
+	changes := DiffConfig(old, cnf)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	var unsafe []Change
+	for _, c := range changes {
+		if isUnsafeField(c.Path) {
+			unsafe = append(unsafe, c)
+		}
+	}
+	if len(unsafe) > 0 {
+		return nil, &UnsafeChangeError{Changes: unsafe}
+	}
+
+	// self、datacnf 与 peerSet 不会变化，沿用已解密的私钥和已构造的 PeerSet
+	cnf.key = old.GetKey()
+	cnf.peers = old.GetPeers()
+	cnf.Peerlist = old.GetPeers().Peers
+	cnf.source = w.file
+
+	w.current.Store(cnf)
+	if Global.Source() == w.file {
+		Global.replace(cnf)
+		if old.Verbose != cnf.Verbose {
+			setLogLevel(cnf.Verbose)
+		}
+	}
+
+	w.mu.Lock()
+	subs := w.subs
+	w.mu.Unlock()
+
+	for _, fn := range subs {
+		fn(cnf, changes)
+	}
+
+	return changes, nil
+}
+
+func (w *Watcher) stat() (time.Time, int64) {
+	info, err := os.Stat(w.file)
+	if err != nil {
+		return time.Time{}, 0
+	}
+
+	return info.ModTime(), info.Size()
+}
+
+func (w *Watcher) modified() bool {
+	modTime, size := w.stat()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if modTime.Equal(w.modTime) && size == w.size {
+		return false
+	}
+
+	w.modTime, w.size = modTime, size
+	return true
+}
+
+func (w *Watcher) reportError(err error) {
+	w.mu.Lock()
+	onError := w.onError
+	w.mu.Unlock()
+
+	if onError != nil {
+		onError(err)
+		return
+	}
+
+	if Logger != nil {
+		Logger.Warnf("failed to reload config %s: %v", w.file, err)
+	}
+}
+
+// DiffConfig 比较两份配置，返回按 mapstructure 路径列出的变化
+func DiffConfig(a, b *Config) []Change {
+	var (
+		changes []Change
+		aKeys   = configKeys(reflect.ValueOf(a), "")
+		bKeys   = configKeys(reflect.ValueOf(b), "")
+	)
+
+	for i := range aKeys {
+		av, bv := aKeys[i].value.Interface(), bKeys[i].value.Interface()
+		if !reflect.DeepEqual(av, bv) {
+			changes = append(changes, Change{Path: aKeys[i].path, Old: av, New: bv})
+		}
+	}
+
+	if !samePeers(a.Peerlist, b.Peerlist) {
+		changes = append(changes, Change{Path: "peerSet", Old: a.Peerlist, New: b.Peerlist})
+	}
+
+	return changes
+}
+
+func isUnsafeField(path string) bool {
+	for _, f := range unsafeFields {
+		if path == f || (strings.HasSuffix(f, ".") && strings.HasPrefix(path, f)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// samePeers 比较两个 peer 列表的配置内容，忽略公钥大小写和缓存的 ID
+func samePeers(a, b []*Peer) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		pa, pb := *a[i], *b[i]
+		pa.PubKeyHex, pb.PubKeyHex = strings.ToUpper(pa.PubKeyHex), strings.ToUpper(pb.PubKeyHex)
+		pa.id, pb.id = 0, 0
+		if !reflect.DeepEqual(pa, pb) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,171 @@
+package conf
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeWatchedConfig(t *testing.T, dir string, replace ...string) {
+	data, err := ioutil.ReadFile(filepath.Join(testFilePath, "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	datadir, _ := filepath.Abs(testFilePath)
+	content := strings.Replace(string(data), `datadir = "./testdata"`, `datadir = "`+datadir+`"`, 1)
+	content = strings.NewReplacer(replace...).Replace(content)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.toml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeWatchedConfig(t, dir)
+	w, err := Watch(dir)
+	if err != nil {
+		t.Fatalf("failed to watch config. cause: %v\n", err)
+	}
+	initial := w.Config()
+
+	var notified []Change
+	w.Subscribe(func(cnf *Config, changes []Change) { notified = changes })
+
+	writeWatchedConfig(t, dir, `heartbeat = "500ms"`, `heartbeat = "200ms"`, "sync-limit = 1000", "sync-limit = 500")
+	changes, err := w.Reload()
+	if err != nil {
+		t.Fatalf("failed to reload config. cause: %v\n", err)
+	}
+
+	if len(changes) != 2 || len(notified) != 2 {
+		t.Fatalf("expected 2 changes, got %v (notified %v)", changes, notified)
+	}
+
+	cnf := w.Config()
+	if cnf == initial || cnf.NetCnf.Heartbeat != 200*time.Millisecond || cnf.SyncLimit != 500 {
+		t.Fatalf("config was not swapped: %+v", cnf.NetCnf)
+	}
+
+	if cnf.GetKey() == nil || cnf.GetPeers() != initial.GetPeers() {
+		t.Fatal("key and peers were not carried over")
+	}
+
+	writeWatchedConfig(t, dir, `self = "node0"`, `self = "node1"`, `datadir = "`, `datadir = "/tmp`)
+	_, err = w.Reload()
+	var unsafeErr *UnsafeChangeError
+	if !errors.As(err, &unsafeErr) || len(unsafeErr.Changes) != 2 {
+		t.Fatalf("expected UnsafeChangeError for self and datadir, got %v", err)
+	}
+
+	if w.Config() != cnf {
+		t.Fatal("config was swapped despite unsafe change")
+	}
+
+	writeWatchedConfig(t, dir, "rotationtime = 24", "rotationtime = 1", "rotationcount = 7", "rotationcount = 3")
+	_, err = w.Reload()
+	if !errors.As(err, &unsafeErr) || len(unsafeErr.Changes) != 2 {
+		t.Fatalf("expected UnsafeChangeError for log rotation, got %v", err)
+	}
+}
+
+func TestWatcherPolling(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeWatchedConfig(t, dir)
+	w, err := Watch(dir)
+	if err != nil {
+		t.Fatalf("failed to watch config. cause: %v\n", err)
+	}
+
+	reloaded := make(chan []Change, 1)
+	w.Subscribe(func(cnf *Config, changes []Change) { reloaded <- changes })
+	w.SetInterval(10 * time.Millisecond)
+	w.Start()
+	defer w.Close()
+
+	writeWatchedConfig(t, dir, "max-pool = 2", "max-pool = 8")
+
+	select {
+	case changes := <-reloaded:
+		if len(changes) != 1 || changes[0].Path != "netcnf.max-pool" {
+			t.Fatalf("unexpected changes %v", changes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher did not pick up file change")
+	}
+}
+
+func TestWatcherReloadGlobal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolaxy-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeWatchedConfig(t, dir)
+	if _, err := TryLoadConfig(dir); err != nil {
+		t.Fatalf("failed to load config. cause: %v\n", err)
+	}
+
+	w, err := Watch(dir)
+	if err != nil {
+		t.Fatalf("failed to watch config. cause: %v\n", err)
+	}
+
+	// 节点在热更新的同时读取 Global，go test -race 可以发现未加锁的访问
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			Global.GetKey()
+			Global.GetPeers()
+			Global.Source()
+		}
+	}()
+
+	writeWatchedConfig(t, dir, "sync-limit = 1000", "sync-limit = 500")
+	if _, err := w.Reload(); err != nil {
+		t.Fatalf("failed to reload config. cause: %v\n", err)
+	}
+	<-done
+
+	if Global.SyncLimit != 500 || Global.GetKey() == nil || Peers != Global.GetPeers() {
+		t.Fatalf("reload was not applied to Global: sync-limit %d", Global.SyncLimit)
+	}
+
+	other, err := ioutil.TempDir("", "bolaxy-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(other)
+
+	writeWatchedConfig(t, other)
+	ow, err := Watch(other)
+	if err != nil {
+		t.Fatalf("failed to watch config. cause: %v\n", err)
+	}
+
+	writeWatchedConfig(t, other, "sync-limit = 1000", "sync-limit = 300")
+	if _, err := ow.Reload(); err != nil {
+		t.Fatalf("failed to reload config. cause: %v\n", err)
+	}
+
+	if Global.SyncLimit != 500 {
+		t.Fatalf("reload of another file modified Global: sync-limit %d", Global.SyncLimit)
+	}
+}